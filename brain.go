@@ -1,7 +1,9 @@
 package cobe
 
 import (
+	"database/sql"
 	"fmt"
+	"io"
 	"log"
 	"math/rand"
 	"strings"
@@ -12,6 +14,26 @@ type Cobe2Brain struct {
 	graph  *graph
 	tok    tokenizer
 	scorer scorer
+
+	// splitSentences makes Learn treat each sentence in its input
+	// as a separate line.
+	splitSentences bool
+
+	// filters reject lines that shouldn't be learned.
+	filters []LearnFilter
+
+	// scrub says what to do with personal data in learned text.
+	scrub ScrubMode
+
+	// moderator, if set, keeps blocked words out of replies.
+	moderator *Moderator
+
+	// requireNovel rejects replies that copy learned sentences.
+	requireNovel bool
+
+	// allowPivot, if set, restricts the classes of tokens replies
+	// are built around.
+	allowPivot func(class TokenClass) bool
 }
 
 const spaceTokenID tokenID = -1
@@ -36,7 +58,11 @@ func OpenCobe2Brain(path string) (*Cobe2Brain, error) {
 		return nil, err
 	}
 
-	return &Cobe2Brain{graph, getTokenizer(tokenizer), &cobeScorer{}}, nil
+	return &Cobe2Brain{
+		graph:  graph,
+		tok:    getTokenizer(tokenizer),
+		scorer: &cobeScorer{},
+	}, nil
 }
 
 func (b *Cobe2Brain) Close() {
@@ -57,43 +83,89 @@ func getTokenizer(name string) tokenizer {
 	return nil
 }
 
+// SetAllowPivot restricts the tokens replies can be built around to
+// those of the classes allow accepts. A nil allow accepts any class.
+func (b *Cobe2Brain) SetAllowPivot(allow func(class TokenClass) bool) {
+	b.allowPivot = allow
+}
+
+// SetSplitSentences controls whether Learn splits its input into
+// sentences, learning each one from start to end context. This suits
+// paragraphs of prose better than learning them as one long chain.
+func (b *Cobe2Brain) SetSplitSentences(split bool) {
+	b.splitSentences = split
+}
+
 func (b *Cobe2Brain) Learn(text string) {
-	now := time.Now()
+	b.LearnRecord(LearnRecord{Text: text})
+}
 
-	tokens := b.tok.Split(text)
+// LearnRecord learns the text of rec. The rest of the record is passed
+// along with it, though only the text affects the brain for now.
+func (b *Cobe2Brain) LearnRecord(rec LearnRecord) {
+	text, ok := b.scrubLearn(rec.Text)
+	if !ok {
+		return
+	}
+	rec.Text = text
 
-	// skip learning if too few tokens (but don't count spaces)
-	if countGoodTokens(tokens) <= b.graph.order {
-		stats.Inc("learn.skipped", 1, 1.0)
+	if b.splitSentences {
+		for _, sentence := range SplitSentences(rec.Text) {
+			b.learn(sentence, &rec)
+		}
 		return
 	}
 
-	stats.Inc("learn.attempted", 1, 1.0)
+	b.learn(rec.Text, &rec)
+}
 
-	var tokenIds []tokenID
-	for _, text := range tokens {
-		var tokenID tokenID
-		if text == " " {
-			tokenID = spaceTokenID
-		} else {
-			tokenID = b.graph.getOrCreateToken(text)
-		}
+// BeginBatch starts a batch of learning. Everything learned until
+// CommitBatch is written in a single transaction, which is much faster
+// for bulk learning and lets a checkpoint commit along with the text
+// it covers.
+func (b *Cobe2Brain) BeginBatch() error {
+	return b.graph.begin()
+}
+
+// CommitBatch commits the batch started by BeginBatch.
+func (b *Cobe2Brain) CommitBatch() error {
+	return b.graph.commit()
+}
+
+// RollbackBatch discards everything learned since BeginBatch.
+func (b *Cobe2Brain) RollbackBatch() error {
+	return b.graph.rollback()
+}
 
-		tokenIds = append(tokenIds, tokenID)
+// Checkpoint returns the value saved with SetCheckpoint under name, or
+// "" if there isn't one.
+func (b *Cobe2Brain) Checkpoint(name string) (string, error) {
+	value, err := b.graph.getInfoString("checkpoint:" + name)
+	if err == sql.ErrNoRows {
+		return "", nil
 	}
 
-	var prevNode nodeID
-	b.forEdges(tokenIds, func(prev, next []tokenID, hasSpace bool) {
-		if prevNode == 0 {
-			prevNode = b.graph.getOrCreateNode(prev)
-		}
-		nextNode := b.graph.getOrCreateNode(next)
+	return value, err
+}
 
-		b.graph.addEdge(prevNode, nextNode, hasSpace)
-		prevNode = nextNode
-	})
+// SetCheckpoint saves a value in the brain under name. During a batch
+// it's saved by CommitBatch, so it always matches what was learned.
+func (b *Cobe2Brain) SetCheckpoint(name, value string) error {
+	return b.graph.setInfoString("checkpoint:"+name, value)
+}
+
+func (b *Cobe2Brain) learn(text string, rec *LearnRecord) {
+	now := time.Now()
+
+	job := b.prepareLearn(text, rec)
+	if job == nil {
+		return
+	}
+
+	if !b.writeLearn(job, nil, nil) {
+		return
+	}
 
-	stats.Inc("learn.succeeded", 1, 1.0)
 	stats.Timing("learn.response_time", int64(time.Since(now)/time.Millisecond), 1.0)
 }
 
@@ -108,18 +180,6 @@ func countGoodTokens(tokens []string) int {
 	return count
 }
 
-func (b *Cobe2Brain) forEdges(tokenIds []tokenID, f func([]tokenID, []tokenID, bool)) {
-	// Call f() on every N-gram (N = brain order) in tokenIds.
-	order := b.graph.order
-
-	chain := b.toChain(order, tokenIds)
-	edges := toEdges(order, chain)
-
-	for _, e := range edges {
-		f(e.prev, e.next, e.hasSpace)
-	}
-}
-
 func (b *Cobe2Brain) toChain(order int, tokenIds []tokenID) []tokenID {
 	var chain []tokenID
 	for i := 0; i < order; i++ {
@@ -189,23 +249,50 @@ func (b *Cobe2Brain) Reply(text string) string {
 }
 
 func (b *Cobe2Brain) ReplyWithOptions(text string, opts ReplyOptions) string {
+	reply := b.BestReply(text, opts)
+	if reply == nil {
+		return "I don't know enough to answer you yet!"
+	}
+
+	return reply.String()
+}
+
+// BestReply returns the reply ReplyWithOptions would give, for callers
+// that want to look into it, or nil if the brain doesn't know enough
+// to answer.
+func (b *Cobe2Brain) BestReply(text string, opts ReplyOptions) *Reply {
 	now := time.Now()
 	stats.Inc("reply.attempted", 1, 1.0)
 
 	tokens := b.tok.Split(text)
 	tokenIds := b.graph.filterPivots(unique(tokens))
 
-	stemTokenIds := b.conflateStems(tokens)
+	stemTokenIds := b.conflateStems(text, tokens)
 	tokenIds = uniqueIds(append(tokenIds, stemTokenIds...))
 
+	synonymTokenIds := b.conflateSynonyms(tokens)
+	tokenIds = uniqueIds(append(tokenIds, synonymTokenIds...))
+
+	foldTokenIds := b.conflateCase(tokens)
+	tokenIds = uniqueIds(append(tokenIds, foldTokenIds...))
+
+	fuzzyTokenIds := b.conflateFuzzy(tokens)
+	tokenIds = uniqueIds(append(tokenIds, fuzzyTokenIds...))
+
+	m := b.moderator
+
+	tokenIds = b.allowPivots(tokenIds, b.allowPivot)
+	tokenIds = b.moderatePivots(tokenIds, m)
+
 	if len(tokenIds) == 0 {
 		stats.Inc("reply.babbled", 1, 1.0)
-		tokenIds = b.babble()
+		tokenIds = b.allowPivots(b.babble(), b.allowPivot)
+		tokenIds = b.moderatePivots(tokenIds, m)
 	}
 
 	if len(tokenIds) == 0 {
 		stats.Inc("error", 1, 1.0)
-		return "I don't know enough to answer you yet!"
+		return nil
 	}
 
 	var count int
@@ -217,6 +304,11 @@ func (b *Cobe2Brain) ReplyWithOptions(text string, opts ReplyOptions) string {
 	var dups int
 	seen := make(map[int]struct{})
 
+	// Candidates turned down by the moderator, and the number of
+	// searches run, so a brain with no acceptable reply gives up.
+	var moderated int
+	searches := 1
+
 	stop := make(chan bool)
 	replies := b.replySearch(tokenIds, stop)
 
@@ -224,13 +316,15 @@ func (b *Cobe2Brain) ReplyWithOptions(text string, opts ReplyOptions) string {
 loop:
 	for {
 		select {
-		case nodes := <-replies:
-			if nodes == nil {
+		case c := <-replies:
+			if c.nodes == nil {
 				// Channel was closed: run another search
 				replies = b.replySearch(tokenIds, stop)
 				continue loop
 			}
 
+			nodes := c.nodes
+
 			stats.Inc("reply.candidate.generated", 1, 1.0)
 
 			h := hash(nodes)
@@ -240,11 +334,21 @@ loop:
 			}
 			seen[h] = struct{}{}
 
-			reply := newReply(b.graph, nodes)
+			reply := newReply(b.graph, nodes, c.pivot)
 			if opts.AllowReply != nil && !opts.AllowReply(reply) {
 				continue
 			}
 
+			if m != nil && !m.allowReply(reply, b.graph.stemmer) {
+				moderated++
+				continue
+			}
+
+			if b.requireNovel && b.graph.isCopy(nodes) {
+				stats.Inc("reply.copied", 1, 1.0)
+				continue
+			}
+
 			score := b.scorer.Score(reply)
 
 			if score > bestScore {
@@ -254,11 +358,21 @@ loop:
 
 			count++
 		case <-timeout:
-			if bestReply != nil {
+			if bestReply != nil || searches >= maxReplySearches {
 				break loop
-			} else {
-				timeout = time.After(opts.Duration)
 			}
+
+			// Every candidate so far was rejected, and the
+			// search may be stuck near its pivot: start again
+			// from another one.
+			close(stop)
+			for range replies {
+			}
+
+			stop = make(chan bool)
+			replies = b.replySearch(tokenIds, stop)
+			timeout = time.After(opts.Duration)
+			searches++
 		}
 	}
 
@@ -271,15 +385,28 @@ loop:
 
 	log.Printf("Got %d unique replies (and %d dups)", count, dups)
 	if bestReply == nil {
-		return "I don't know enough to answer you yet!"
+		if moderated > 0 {
+			stats.Inc("reply.moderated", 1, 1.0)
+		}
+		stats.Inc("reply.failed", 1, 1.0)
+		return nil
+	}
+
+	if m != nil && m.Mask {
+		bestReply.text = m.mask(bestReply, b.graph.stemmer)
+		bestReply.hasText = true
 	}
 
-	ret := bestReply.String()
 	stats.Inc("reply.succeeded", 1, 1.0)
 	stats.Timing("reply.response_time", int64(time.Since(now)/time.Millisecond), 1.0)
-	return ret
+	return bestReply
 }
 
+// maxReplySearches is the number of times BestReply searches for
+// opts.Duration before giving up on a brain where every candidate is
+// rejected.
+const maxReplySearches = 5
+
 func hash(nodes []nodeID) int {
 	h := 17
 	for _, n := range nodes {
@@ -289,9 +416,18 @@ func hash(nodes []nodeID) int {
 	return h
 }
 
-func (b *Cobe2Brain) conflateStems(tokens []string) []tokenID {
+func (b *Cobe2Brain) conflateStems(text string, tokens []string) []tokenID {
 	var ret []tokenID
 
+	if lang := b.graph.detectLanguage(text); lang != "" {
+		for _, token := range tokens {
+			tokenIds := b.graph.getTokensByLangStem(token, lang)
+			ret = append(ret, tokenIds...)
+		}
+
+		return ret
+	}
+
 	for _, token := range tokens {
 		tokenIds := b.graph.getTokensByStem(token)
 		ret = append(ret, tokenIds...)
@@ -300,6 +436,66 @@ func (b *Cobe2Brain) conflateStems(tokens []string) []tokenID {
 	return ret
 }
 
+func (b *Cobe2Brain) conflateSynonyms(tokens []string) []tokenID {
+	var ret []tokenID
+
+	for _, token := range tokens {
+		tokenIds := b.graph.getTokensBySynonym(token)
+		ret = append(ret, tokenIds...)
+	}
+
+	return ret
+}
+
+// conflateCase finds case-insensitive matches for any tokens that
+// aren't known exactly.
+func (b *Cobe2Brain) conflateCase(tokens []string) []tokenID {
+	var ret []tokenID
+
+	for _, token := range tokens {
+		if _, err := b.graph.getTokenID(token); err == nil {
+			continue
+		}
+
+		tokenIds := b.graph.getTokensByFold(token)
+		ret = append(ret, tokenIds...)
+	}
+
+	return ret
+}
+
+// conflateFuzzy finds the nearest known words for any tokens that
+// aren't known exactly, to handle misspelled input.
+func (b *Cobe2Brain) conflateFuzzy(tokens []string) []tokenID {
+	var ret []tokenID
+
+	for _, token := range tokens {
+		if _, err := b.graph.getTokenID(token); err == nil {
+			continue
+		}
+
+		tokenIds := b.graph.getTokensByFuzzy(token)
+		ret = append(ret, tokenIds...)
+	}
+
+	return ret
+}
+
+func (b *Cobe2Brain) allowPivots(tokenIds []tokenID, allow func(TokenClass) bool) []tokenID {
+	if allow == nil {
+		return tokenIds
+	}
+
+	var ret []tokenID
+	for _, id := range tokenIds {
+		if allow(b.graph.getTokenClass(id)) {
+			ret = append(ret, id)
+		}
+	}
+
+	return ret
+}
+
 func (b *Cobe2Brain) babble() []tokenID {
 	var tokenIds []tokenID
 
@@ -315,7 +511,14 @@ func (b *Cobe2Brain) babble() []tokenID {
 
 // replySearch combines a forward and a reverse search over the graph
 // into a series of replies.
-func (b *Cobe2Brain) replySearch(tokenIds []tokenID, stop <-chan bool) <-chan []nodeID {
+// A candidate is a reply found by replySearch: its node path and the
+// index of the pivot node in it.
+type candidate struct {
+	nodes []nodeID
+	pivot int
+}
+
+func (b *Cobe2Brain) replySearch(tokenIds []tokenID, stop <-chan bool) <-chan candidate {
 	pivotID := b.pickPivot(tokenIds)
 	pivotNode := b.graph.getRandomNodeWithToken(pivotID)
 
@@ -324,7 +527,7 @@ func (b *Cobe2Brain) replySearch(tokenIds []tokenID, stop <-chan bool) <-chan []
 	revIter := &history{b.graph.search(pivotNode, endNode, reverse, stop), nil}
 	fwdIter := &history{b.graph.search(pivotNode, endNode, forward, stop), nil}
 
-	replies := make(chan []nodeID)
+	replies := make(chan candidate)
 
 	go func() {
 	loop:
@@ -335,7 +538,7 @@ func (b *Cobe2Brain) replySearch(tokenIds []tokenID, stop <-chan bool) <-chan []
 				result := revIter.result()
 				for _, f := range fwdIter.h {
 					select {
-					case replies <- join(result, f):
+					case replies <- candidate{join(result, f), len(result) - 1}:
 						// nothing
 					case <-stop:
 						break loop
@@ -349,7 +552,7 @@ func (b *Cobe2Brain) replySearch(tokenIds []tokenID, stop <-chan bool) <-chan []
 				result := fwdIter.result()
 				for _, r := range revIter.h {
 					select {
-					case replies <- join(r, result):
+					case replies <- candidate{join(r, result), len(r) - 1}:
 						// nothing
 					case <-stop:
 						break loop
@@ -433,52 +636,158 @@ func uniqueIds(ids []tokenID) []tokenID {
 }
 
 type Reply struct {
-	graph   *graph
-	nodes   []nodeID
+	graph *graph
+	nodes []nodeID
+
+	// pivot is the index in nodes of the node the reply was
+	// searched from.
+	pivot int
+
 	hasText bool
 	text    string
 }
 
-func newReply(graph *graph, nodes []nodeID) *Reply {
-	return &Reply{graph, nodes, false, ""}
+func newReply(graph *graph, nodes []nodeID, pivot int) *Reply {
+	return &Reply{graph, nodes, pivot, false, ""}
 }
 
 func (r *Reply) String() string {
 	if !r.hasText {
-		var parts []string
+		r.hasText = true
+		r.text = r.join(nil)
+	}
 
-		for i := 1; i < len(r.nodes)-r.graph.order; i++ {
-			prev := r.nodes[i]
-			next := r.nodes[i+1]
+	return r.text
+}
 
-			word, hasSpace, err := r.graph.getTextByNodes(prev, next)
-			if err != nil {
-				stats.Inc("error", 1, 1.0)
-				log.Printf("can't get text: %s", err)
-			}
+// join builds the text of the reply, passing each word through f
+// first if it is set.
+func (r *Reply) join(f func(word string) string) string {
+	var parts []string
 
-			if word == "" {
-				stats.Inc("error", 1, 1.0)
-				log.Printf("empty node text: %v", r.nodes)
-			}
+	for i := 1; i < len(r.nodes)-r.graph.order; i++ {
+		prev := r.nodes[i]
+		next := r.nodes[i+1]
 
-			parts = append(parts, word)
-			if hasSpace {
-				parts = append(parts, " ")
-			}
+		word, hasSpace, err := r.graph.getTextByNodes(prev, next)
+		if err != nil {
+			stats.Inc("error", 1, 1.0)
+			log.Printf("can't get text: %s", err)
 		}
 
-		r.hasText = true
-		r.text = strings.Join(parts, "")
+		if word == "" {
+			stats.Inc("error", 1, 1.0)
+			log.Printf("empty node text: %v", r.nodes)
+		}
+
+		if f != nil {
+			word = f(word)
+		}
+
+		parts = append(parts, word)
+		if hasSpace {
+			parts = append(parts, " ")
+		}
 	}
 
-	return r.text
+	return strings.Join(parts, "")
+}
+
+// words returns the words of the reply, not counting spaces.
+func (r *Reply) words() []string {
+	var ret []string
+	r.join(func(word string) string {
+		ret = append(ret, word)
+		return word
+	})
+
+	return ret
+}
+
+// Classes returns the class of each token in the reply, not
+// counting spaces.
+func (r *Reply) Classes() []TokenClass {
+	var ret []TokenClass
+
+	for i := 1; i < len(r.nodes)-r.graph.order; i++ {
+		ret = append(ret, r.graph.getNodeClass(r.nodes[i]))
+	}
+
+	return ret
+}
+
+// HasClass reports whether the reply contains a token of class c.
+func (r *Reply) HasClass(c TokenClass) bool {
+	for _, class := range r.Classes() {
+		if class == c {
+			return true
+		}
+	}
+
+	return false
 }
 
 func (b *Cobe2Brain) DelStemmer() error {
 	return b.graph.delStemmer()
 }
 
-func (b *Cobe2Brain) SetStemmer(lang string) error {
-	return b.graph.setStemmer(lang)
+// SetStemmer stems all known tokens with the named stemmer, and
+// remembers it for tokens learned later. The name is either a
+// snowball language or a stemmer added with RegisterStemmer.
+func (b *Cobe2Brain) SetStemmer(name string) error {
+	return b.graph.setStemmer(name)
+}
+
+// SetLanguages enables language detection among langs, which are
+// snowball language names. Each learned line and each reply input is
+// stemmed in its own language, replacing any stemmer set with
+// SetStemmer.
+func (b *Cobe2Brain) SetLanguages(langs ...string) error {
+	if len(langs) == 0 {
+		return fmt.Errorf("no languages given")
+	}
+
+	return b.graph.setLanguages(langs)
+}
+
+func (b *Cobe2Brain) DelCaseFold() error {
+	return b.graph.delCaseFold()
+}
+
+func (b *Cobe2Brain) SetCaseFold() error {
+	return b.graph.setCaseFold()
+}
+
+func (b *Cobe2Brain) DelFuzzy() error {
+	return b.graph.delFuzzy()
+}
+
+// SetFuzzy enables matching misspelled input words to known words
+// within maxDist edits when picking reply pivots.
+func (b *Cobe2Brain) SetFuzzy(maxDist int) error {
+	return b.graph.setFuzzy(maxDist)
+}
+
+// AddSynonyms makes words equivalent when picking reply pivots, by
+// adding them to the class named class.
+func (b *Cobe2Brain) AddSynonyms(class string, words ...string) error {
+	return b.graph.addSynonyms(class, words)
+}
+
+func (b *Cobe2Brain) DelSynonyms() error {
+	return b.graph.delSynonyms()
+}
+
+// ImportSynonyms reads a synonym list from r and adds it to the
+// brain. See ParseSynonyms for the format.
+func (b *Cobe2Brain) ImportSynonyms(r io.Reader) error {
+	return ParseSynonyms(r, b.AddSynonyms)
+}
+
+// Migrate updates the schema of a brain created by an older version.
+// Older brains work as they are, but are never changed on open;
+// migrating stores each token's class rather than working it out
+// from the token's text on every read.
+func (b *Cobe2Brain) Migrate() error {
+	return b.graph.migrate()
 }
@@ -83,6 +83,48 @@ func TestAllowReply(t *testing.T) {
 	}
 }
 
+func TestReplyClasses(t *testing.T) {
+	filename, err := tmpCopy("data/pg11.brain")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer os.Remove(filename)
+
+	b, err := OpenCobe2Brain(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var pivots []TokenClass
+	b.SetAllowPivot(func(class TokenClass) bool {
+		pivots = append(pivots, class)
+		return class != PunctuationToken
+	})
+
+	opts := ReplyOptions{DefaultReplyOptions.Duration, func(reply *Reply) bool {
+		return reply.HasClass(WordToken)
+	}}
+
+	if r := b.ReplyWithOptions("Alice !", opts); r == "" {
+		t.Fatal("got a nil reply")
+	}
+
+	var words, puncts int
+	for _, class := range pivots {
+		switch class {
+		case WordToken:
+			words++
+		case PunctuationToken:
+			puncts++
+		}
+	}
+
+	if words == 0 || puncts != 1 {
+		t.Errorf("Expected word and punctuation pivots, got %v", pivots)
+	}
+}
+
 // Run looped learn/reply on a brain to try to reproduce sqlite3 errors.
 func TestLoop(t *testing.T) {
 	// Test with an unreasonable number of GOMAXPROCS. This is a
@@ -205,3 +247,110 @@ func edgeEqual(a edge, b edge) bool {
 	return nodeEqual(a.prev, b.prev) && nodeEqual(a.next, b.next) &&
 		a.hasSpace == b.hasSpace
 }
+
+func TestLearnSentences(t *testing.T) {
+	filename, err := tmpCopy("data/pg11.brain")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer os.Remove(filename)
+
+	b, err := OpenCobe2Brain(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b.SetSplitSentences(true)
+	b.Learn("The platypus swam away quickly. The lynx watched it go.")
+
+	// Each sentence ends at the end context, so "." is followed by
+	// the end token rather than "The".
+	g := b.graph
+	end := g.endTokenID
+	period, _ := g.getTokenID(".")
+	the, _ := g.getTokenID("The")
+	away, _ := g.getTokenID("away")
+	quickly, _ := g.getTokenID("quickly")
+
+	var count int
+	err = g.db.QueryRow("SELECT count(*) FROM nodes "+
+		"WHERE token0_id = ? AND token1_id = ? AND token2_id = ?",
+		quickly, period, end).Scan(&count)
+	if err != nil || count != 1 {
+		t.Errorf("Expected a node ending the first sentence: %d %v", count, err)
+	}
+
+	err = g.db.QueryRow("SELECT count(*) FROM nodes, edges "+
+		"WHERE nodes.token0_id = ? AND nodes.token1_id = ? AND nodes.token2_id = ? "+
+		"AND edges.prev_node = nodes.id", away, quickly, period).Scan(&count)
+	if err != nil || count != 1 {
+		t.Errorf("Expected one edge after 'away quickly .': %d %v", count, err)
+	}
+
+	err = g.db.QueryRow("SELECT count(*) FROM nodes "+
+		"WHERE token0_id = ? AND token1_id = ? AND token2_id = ?",
+		quickly, period, the).Scan(&count)
+	if err != nil || count != 0 {
+		t.Errorf("Expected no node joining the sentences: %d %v", count, err)
+	}
+}
+
+func TestLearnBatch(t *testing.T) {
+	filename, err := tmpCopy("data/pg11.brain")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer os.Remove(filename)
+
+	b, err := OpenCobe2Brain(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Close()
+
+	learn := func(text, checkpoint string) {
+		err := b.BeginBatch()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		b.Learn(text)
+
+		err = b.SetCheckpoint("test", checkpoint)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	learn("the zyzzyva crawled over the kumquat", "1")
+	if err = b.RollbackBatch(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := b.graph.getTokenID("zyzzyva"); err == nil {
+		t.Error("zyzzyva survived a rollback")
+	}
+
+	if cp, err := b.Checkpoint("test"); cp != "" || err != nil {
+		t.Errorf("Expected no checkpoint, got %q (%v)", cp, err)
+	}
+
+	learn("the zyzzyva crawled over the kumquat", "2")
+	if err = b.CommitBatch(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := b.graph.getTokenID("zyzzyva"); err != nil {
+		t.Error("zyzzyva wasn't committed")
+	}
+
+	if cp, err := b.Checkpoint("test"); cp != "2" || err != nil {
+		t.Errorf("Expected checkpoint 2, got %q (%v)", cp, err)
+	}
+
+	if err = b.CommitBatch(); err == nil {
+		t.Error("Expected an error committing without a batch")
+	}
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+	"time"
+
+	cobe "github.com/pteichman/go.cobe"
+)
+
+// Helpers shared by the chat export formats.
+
+// emojiCodes maps common :shortcode: emoji to Unicode, so they stem
+// like the emoji people type directly.
+var emojiCodes = map[string]string{
+	"grinning":                     "😀",
+	"smiley":                       "😃",
+	"smile":                        "😄",
+	"slightly_smiling_face":        "🙂",
+	"blush":                        "😊",
+	"joy":                          "😂",
+	"rofl":                         "🤣",
+	"laughing":                     "😆",
+	"satisfied":                    "😆",
+	"sweat_smile":                  "😅",
+	"heart":                        "❤️",
+	"heart_eyes":                   "😍",
+	"kissing_heart":                "😘",
+	"broken_heart":                 "💔",
+	"wink":                         "😉",
+	"stuck_out_tongue":             "😛",
+	"stuck_out_tongue_winking_eye": "😜",
+	"disappointed":                 "😞",
+	"cry":                          "😢",
+	"sob":                          "😭",
+	"frowning":                     "😦",
+	"slightly_frowning_face":       "🙁",
+	"angry":                        "😠",
+	"rage":                         "😡",
+	"open_mouth":                   "😮",
+	"astonished":                   "😲",
+	"scream":                       "😱",
+	"flushed":                      "😳",
+	"exploding_head":               "🤯",
+	"+1":                           "👍",
+	"thumbsup":                     "👍",
+	"-1":                           "👎",
+	"thumbsdown":                   "👎",
+	"ok_hand":                      "👌",
+	"raised_hands":                 "🙌",
+	"white_check_mark":             "✅",
+	"heavy_check_mark":             "✔️",
+	"x":                            "❌",
+	"thinking_face":                "🤔",
+	"thinking":                     "🤔",
+	"tada":                         "🎉",
+	"fire":                         "🔥",
+	"eyes":                         "👀",
+	"wave":                         "👋",
+	"pray":                         "🙏",
+	"clap":                         "👏",
+	"100":                          "💯",
+	"shrug":                        "🤷",
+}
+
+// Codes start with a letter so times like 12:30:45 are left alone.
+var emojiCodeRegexp = regexp.MustCompile(`:([a-z][a-z0-9_+-]*|[+-]1|100):`)
+
+// decodeEmojiCodes replaces :shortcode: emoji with Unicode. Unknown
+// codes are left as they are, since they may just be text with
+// colons in it.
+func decodeEmojiCodes(text string) string {
+	return emojiCodeRegexp.ReplaceAllStringFunc(text, func(code string) string {
+		if emoji, ok := emojiCodes[code[1:len(code)-1]]; ok {
+			return emoji
+		}
+		return code
+	})
+}
+
+// Markdown links, [label](url), as used by Mattermost and Discord.
+var markdownLinkRegexp = regexp.MustCompile(`\[([^\]]*)\]\([^)]*\)`)
+
+var markdownReplacer = strings.NewReplacer("**", "", "__", "", "~~", "", "`", "")
+
+// decodeMarkdown keeps the label of markdown links and drops
+// emphasis markers.
+func decodeMarkdown(text string) string {
+	text = markdownLinkRegexp.ReplaceAllString(text, "$1")
+	return markdownReplacer.Replace(text)
+}
+
+// learnChatText learns each line of a chat message, after decoding
+// emoji codes and collapsing whitespace.
+func learnChatText(text, author, channel string, ts time.Time, learn func(cobe.LearnRecord)) {
+	text = decodeEmojiCodes(text)
+
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.Join(strings.Fields(line), " ")
+		if line != "" {
+			learn(cobe.LearnRecord{
+				Text:      line,
+				Author:    author,
+				Channel:   channel,
+				Timestamp: ts,
+			})
+		}
+	}
+}
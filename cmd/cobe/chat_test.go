@@ -0,0 +1,176 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	cobe "github.com/pteichman/go.cobe"
+)
+
+// writeFiles creates files, keyed by slash-separated path, in a new
+// temporary directory.
+func writeFiles(t *testing.T, files map[string]string) string {
+	dir, err := ioutil.TempDir("", "cobe-chat")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, data := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+
+		err = os.MkdirAll(filepath.Dir(path), 0755)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = ioutil.WriteFile(path, []byte(data), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return dir
+}
+
+func learnAll(t *testing.T, read learnFormat, path string, opts learnOptions) []string {
+	var lines []string
+	err := read(path, &opts, func(rec cobe.LearnRecord) {
+		lines = append(lines, rec.Text)
+	})
+	if err != nil {
+		t.Fatalf("%s: %s", path, err)
+	}
+
+	return lines
+}
+
+func TestDecodeEmojiCodes(t *testing.T) {
+	var tests = []struct {
+		text     string
+		expected string
+	}{
+		{"nice :+1:", "nice 👍"},
+		{":tada: :partyparrot: done", "🎉 :partyparrot: done"},
+		{"at 12:30:45", "at 12:30:45"},
+		{"ratio 1:2", "ratio 1:2"},
+		{"foo:bar:baz", "foo:bar:baz"},
+		{"see 10:30:00", "see 10:30:00"},
+	}
+
+	for ti, tt := range tests {
+		got := decodeEmojiCodes(tt.text)
+		if got != tt.expected {
+			t.Errorf("[%d] %q: expected %q, got %q", ti, tt.text, tt.expected, got)
+		}
+	}
+}
+
+func TestLearnSlack(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"users.json": `[
+			{"id": "U1", "name": "alice"},
+			{"id": "U2", "name": "bob"},
+			{"id": "U3", "name": "helper", "is_bot": true}]`,
+		"general/2014-03-01.json": `[
+			{"type": "message", "subtype": "channel_join", "user": "U1", "text": "<@U1> has joined the channel"},
+			{"type": "message", "user": "U1", "text": "hi <@U2>, see <https://example.com/|the docs> &amp; <#C1|random> :smile:"},
+			{"type": "message", "subtype": "bot_message", "bot_id": "B1", "text": "beep"},
+			{"type": "message", "user": "U3", "text": "boop"},
+			{"type": "message", "user": "U2", "text": "<!here> first line\nsecond line"}]`,
+		"random/2014-03-02.json": `[
+			{"type": "message", "user": "U2", "text": "random &lt;stuff&gt;"}]`,
+	})
+	defer os.RemoveAll(dir)
+
+	var tests = []struct {
+		path     string
+		opts     learnOptions
+		expected []string
+	}{
+		{dir, learnOptions{}, []string{
+			"hi @bob, see the docs & #random 😄",
+			"@here first line",
+			"second line",
+			"random <stuff>",
+		}},
+		{dir, learnOptions{Channels: []string{"random"}}, []string{
+			"random <stuff>",
+		}},
+		{dir, learnOptions{ExcludeUsers: []string{"bob"}}, []string{
+			"hi @bob, see the docs & #random 😄",
+		}},
+		{filepath.Join(dir, "random", "2014-03-02.json"), learnOptions{}, []string{
+			"random <stuff>",
+		}},
+	}
+
+	for ti, tt := range tests {
+		got := learnAll(t, learnSlack, tt.path, tt.opts)
+		if !reflect.DeepEqual(got, tt.expected) {
+			t.Errorf("[%d] expected %q, got %q", ti, tt.expected, got)
+		}
+	}
+}
+
+func TestLearnMattermost(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"export.jsonl": `{"type": "version", "version": 1}
+{"type": "user", "user": {"username": "alice"}}
+{"type": "post", "post": {"team": "t", "channel": "town-square", "user": "alice", "message": "read **this** [post](https://example.com/) :wink:", "replies": [{"user": "bob", "message": "thanks"}]}}
+{"type": "post", "post": {"team": "t", "channel": "town-square", "user": "alice", "message": "alice joined the channel", "type": "system_join_channel"}}
+{"type": "post", "post": {"team": "t", "channel": "town-square", "user": "ci", "message": "build passed", "props": {"from_bot": "true"}}}
+{"type": "direct_post", "direct_post": {"channel_members": ["alice", "bob"], "user": "bob", "message": "psst"}}
+`,
+	})
+	defer os.RemoveAll(dir)
+
+	var tests = []struct {
+		opts     learnOptions
+		expected []string
+	}{
+		{learnOptions{}, []string{"read this post 😉", "thanks", "psst"}},
+		{learnOptions{Users: []string{"bob"}}, []string{"thanks", "psst"}},
+		{learnOptions{ExcludeChannels: []string{"town-square"}}, []string{"psst"}},
+	}
+
+	for ti, tt := range tests {
+		got := learnAll(t, learnMattermost, filepath.Join(dir, "export.jsonl"), tt.opts)
+		if !reflect.DeepEqual(got, tt.expected) {
+			t.Errorf("[%d] expected %q, got %q", ti, tt.expected, got)
+		}
+	}
+}
+
+func TestLearnDiscord(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"general.json": `{
+			"channel": {"name": "general"},
+			"messages": [
+				{"type": "GuildMemberJoin", "content": "", "author": {"id": "1", "name": "alice"}},
+				{"type": "Default", "content": "hey <@!2> <:pepe:123> ~~no~~ yes :fire:", "author": {"id": "1", "name": "alice"}, "mentions": [{"id": "2", "name": "bob"}]},
+				{"type": "Reply", "content": "see <#456> and [this](https://example.com/)", "author": {"id": "2", "name": "bob"}},
+				{"type": "Default", "content": "I am a bot", "author": {"id": "3", "name": "mee6", "isBot": true}},
+				{"type": "ChannelPinnedMessage", "content": "pinned", "author": {"id": "2", "name": "bob"}}
+			]}`,
+	})
+	defer os.RemoveAll(dir)
+
+	var tests = []struct {
+		opts     learnOptions
+		expected []string
+	}{
+		{learnOptions{}, []string{"hey @bob no yes 🔥", "see and this"}},
+		{learnOptions{Users: []string{"Alice"}}, []string{"hey @bob no yes 🔥"}},
+		{learnOptions{Channels: []string{"random"}}, nil},
+	}
+
+	for ti, tt := range tests {
+		got := learnAll(t, learnDiscord, dir, tt.opts)
+		if !reflect.DeepEqual(got, tt.expected) {
+			t.Errorf("[%d] expected %q, got %q", ti, tt.expected, got)
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"time"
+
+	cobe "github.com/pteichman/go.cobe"
+)
+
+// The JSON written by DiscordChatExporter, one file per channel.
+type discordExport struct {
+	Channel struct {
+		Name string `json:"name"`
+	} `json:"channel"`
+	Messages []discordMessage `json:"messages"`
+}
+
+type discordMessage struct {
+	Type      string        `json:"type"`
+	Timestamp time.Time     `json:"timestamp"`
+	Content   string        `json:"content"`
+	Author    discordUser   `json:"author"`
+	Mentions  []discordUser `json:"mentions"`
+}
+
+type discordUser struct {
+	ID    string `json:"id"`
+	Name  string `json:"name"`
+	IsBot bool   `json:"isBot"`
+}
+
+// learnDiscord learns the messages in a Discord channel export, or in
+// every .json file in a directory of them.
+func learnDiscord(path string, opts *learnOptions, learn func(cobe.LearnRecord)) error {
+	files := []string{path}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+
+	if info.IsDir() {
+		files, err = filepath.Glob(filepath.Join(path, "*.json"))
+		if err != nil {
+			return err
+		}
+		sort.Strings(files)
+	}
+
+	for _, file := range files {
+		err = learnDiscordFile(file, opts, learn)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func learnDiscordFile(path string, opts *learnOptions, learn func(cobe.LearnRecord)) error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	var export discordExport
+	err = json.Unmarshal(data, &export)
+	if err != nil {
+		return err
+	}
+
+	for _, msg := range export.Messages {
+		// Other types are joins, pins, boosts and the like.
+		if msg.Type != "Default" && msg.Type != "Reply" {
+			continue
+		}
+
+		if msg.Author.IsBot || !opts.allow(export.Channel.Name, msg.Author.Name) {
+			continue
+		}
+
+		learnChatText(decodeDiscord(msg.Content, msg.Mentions), msg.Author.Name,
+			export.Channel.Name, msg.Timestamp, learn)
+	}
+
+	return nil
+}
+
+var (
+	discordMentionRegexp = regexp.MustCompile(`<@!?(\d+)>`)
+	discordMarkupRegexp  = regexp.MustCompile(`<(a?:\w+:|#|@&)\d+>`)
+)
+
+// decodeDiscord resolves user mentions and drops the channel, role
+// and custom emoji markup that has no useful text.
+func decodeDiscord(text string, mentions []discordUser) string {
+	text = discordMentionRegexp.ReplaceAllStringFunc(text, func(m string) string {
+		id := discordMentionRegexp.FindStringSubmatch(m)[1]
+		for _, u := range mentions {
+			if u.ID == id {
+				return "@" + u.Name
+			}
+		}
+
+		return ""
+	})
+
+	text = discordMarkupRegexp.ReplaceAllString(text, "")
+	return decodeMarkdown(text)
+}
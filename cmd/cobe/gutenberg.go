@@ -0,0 +1,79 @@
+package main
+
+import (
+	"io"
+	"io/ioutil"
+	"strings"
+)
+
+// Markers around the text of a Project Gutenberg etext. Older etexts
+// say THIS, newer ones say THE.
+var gutenbergStart = []string{
+	"*** START OF THIS PROJECT GUTENBERG EBOOK",
+	"*** START OF THE PROJECT GUTENBERG EBOOK",
+}
+
+var gutenbergEnd = []string{
+	"*** END OF THIS PROJECT GUTENBERG EBOOK",
+	"*** END OF THE PROJECT GUTENBERG EBOOK",
+}
+
+// learnGutenberg is a port of data/ungutenberg.py. It skips the
+// license header and footer of a Project Gutenberg etext and learns
+// each paragraph as a single line.
+func learnGutenberg(r io.Reader, learn func(string)) error {
+	text, err := ioutil.ReadAll(r)
+	if err != nil {
+		return err
+	}
+
+	for _, p := range gutenbergParagraphs(string(text)) {
+		learn(p)
+	}
+
+	return nil
+}
+
+func gutenbergParagraphs(text string) []string {
+	// Convert DOS line endings to Unix for convenience, and split
+	// all the paragraphs into separate strings.
+	text = strings.Replace(text, "\r\n", "\n", -1)
+
+	var paragraphs []string
+	for _, p := range strings.Split(text, "\n\n") {
+		// Trim like Python's str.strip() on bytes.
+		paragraphs = append(paragraphs, strings.Trim(p, " \t\n\r\v\f"))
+	}
+
+	start := indexPrefix(paragraphs, gutenbergStart)
+	end := indexPrefix(paragraphs, gutenbergEnd)
+	if end <= start {
+		// No end marker, or a stray one before the start in a
+		// malformed or concatenated etext.
+		end = len(paragraphs)
+	}
+
+	var ret []string
+	for _, p := range paragraphs[start+1 : end] {
+		s := strings.Replace(p, "\n", " ", -1)
+		if len(s) > 0 {
+			ret = append(ret, s)
+		}
+	}
+
+	return ret
+}
+
+// indexPrefix returns the index of the first string in items that
+// starts with any of prefixes, or -1.
+func indexPrefix(items []string, prefixes []string) int {
+	for i, item := range items {
+		for _, prefix := range prefixes {
+			if strings.HasPrefix(item, prefix) {
+				return i
+			}
+		}
+	}
+
+	return -1
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestGutenbergParagraphs(t *testing.T) {
+	text := "Project Gutenberg's Test\r\n\r\n" +
+		"*** START OF THIS PROJECT GUTENBERG EBOOK TEST ***\r\n\r\n" +
+		"First paragraph,\r\nwrapped over\r\nthree lines.\r\n\r\n" +
+		"  Second paragraph.  \r\n\r\n\r\n\r\n" +
+		"*** END OF THIS PROJECT GUTENBERG EBOOK TEST ***\r\n\r\n" +
+		"License text.\r\n"
+
+	var lines []string
+	err := learnGutenberg(strings.NewReader(text), func(s string) {
+		lines = append(lines, s)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{
+		"First paragraph, wrapped over three lines.",
+		"Second paragraph.",
+	}
+
+	if strings.Join(lines, "|") != strings.Join(expected, "|") {
+		t.Errorf("Expected %q, was %q", expected, lines)
+	}
+}
+
+// An END marker before the START, as in concatenated etexts, is
+// ignored.
+func TestGutenbergEndBeforeStart(t *testing.T) {
+	text := "*** END OF THE PROJECT GUTENBERG EBOOK ONE ***\n\n" +
+		"License text.\n\n" +
+		"*** START OF THE PROJECT GUTENBERG EBOOK TWO ***\n\n" +
+		"The second book.\n"
+
+	expected := []string{"The second book."}
+
+	lines := gutenbergParagraphs(text)
+	if strings.Join(lines, "|") != strings.Join(expected, "|") {
+		t.Errorf("Expected %q, was %q", expected, lines)
+	}
+}
+
+// pg11.brain was trained on the output of ungutenberg.py, which
+// learnGutenberg should reproduce.
+func TestGutenbergAlice(t *testing.T) {
+	f, err := os.Open("../../data/pg11.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	var lines []string
+	err = learnGutenberg(f, func(s string) {
+		lines = append(lines, s)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(lines) != 820 {
+		t.Errorf("Expected 820 paragraphs, was %d", len(lines))
+	}
+
+	if lines[0] != "ALICE'S ADVENTURES IN WONDERLAND" {
+		t.Errorf("Unexpected first paragraph: %s", lines[0])
+	}
+
+	last := "End of Project Gutenberg's Alice's Adventures in Wonderland, by Lewis Carroll"
+	if lines[len(lines)-1] != last {
+		t.Errorf("Unexpected last paragraph: %s", lines[len(lines)-1])
+	}
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"io"
+	"strings"
+
+	cobe "github.com/pteichman/go.cobe"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// learnHTML learns the sentences in the visible prose of an HTML
+// document. Scripts, code blocks, tables, forms and navigation are
+// skipped, and links are learned by their text alone.
+func learnHTML(r io.Reader, learn func(string)) error {
+	doc, err := html.Parse(r)
+	if err != nil {
+		return err
+	}
+
+	for _, p := range htmlParagraphs(doc) {
+		for _, s := range cobe.SplitSentences(p) {
+			learn(s)
+		}
+	}
+
+	return nil
+}
+
+// Elements whose contents aren't prose.
+var htmlSkip = map[atom.Atom]bool{
+	atom.Head:     true,
+	atom.Script:   true,
+	atom.Style:    true,
+	atom.Noscript: true,
+	atom.Template: true,
+	atom.Nav:      true,
+	atom.Header:   true,
+	atom.Footer:   true,
+	atom.Aside:    true,
+	atom.Menu:     true,
+	atom.Pre:      true,
+	atom.Table:    true,
+	atom.Form:     true,
+	atom.Button:   true,
+	atom.Select:   true,
+	atom.Textarea: true,
+	atom.Svg:      true,
+	atom.Math:     true,
+	atom.Iframe:   true,
+	atom.Object:   true,
+	atom.Figure:   true,
+}
+
+// Elements that end the current paragraph.
+var htmlBlock = map[atom.Atom]bool{
+	atom.Address:    true,
+	atom.Article:    true,
+	atom.Blockquote: true,
+	atom.Br:         true,
+	atom.Dd:         true,
+	atom.Div:        true,
+	atom.Dl:         true,
+	atom.Dt:         true,
+	atom.Fieldset:   true,
+	atom.H1:         true,
+	atom.H2:         true,
+	atom.H3:         true,
+	atom.H4:         true,
+	atom.H5:         true,
+	atom.H6:         true,
+	atom.Hr:         true,
+	atom.Li:         true,
+	atom.Main:       true,
+	atom.Ol:         true,
+	atom.P:          true,
+	atom.Section:    true,
+	atom.Ul:         true,
+}
+
+func htmlParagraphs(doc *html.Node) []string {
+	var paragraphs []string
+	var cur []string
+
+	flush := func() {
+		if p := strings.Join(strings.Fields(strings.Join(cur, "")), " "); p != "" {
+			paragraphs = append(paragraphs, p)
+		}
+		cur = nil
+	}
+
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		switch n.Type {
+		case html.TextNode:
+			cur = append(cur, n.Data)
+			return
+		case html.ElementNode:
+			if htmlSkip[n.DataAtom] || htmlHidden(n) {
+				// Keep the words on either side apart.
+				cur = append(cur, " ")
+				return
+			}
+		}
+
+		block := htmlBlock[n.DataAtom]
+		if block {
+			flush()
+		}
+
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+
+		if block {
+			flush()
+		}
+	}
+
+	walk(doc)
+	flush()
+
+	return paragraphs
+}
+
+// htmlHidden reports whether an element is marked as hidden or as
+// navigation with attributes rather than by its tag.
+func htmlHidden(n *html.Node) bool {
+	for _, a := range n.Attr {
+		switch {
+		case a.Key == "hidden":
+			return true
+		case a.Key == "aria-hidden" && a.Val == "true":
+			return true
+		case a.Key == "role" && (a.Val == "navigation" || a.Val == "banner" || a.Val == "contentinfo"):
+			return true
+		}
+	}
+
+	return false
+}
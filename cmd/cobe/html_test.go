@@ -0,0 +1,83 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLearnHTML(t *testing.T) {
+	doc := `<!DOCTYPE html>
+<html><head><title>Wiki</title><style>p { color: red }</style></head>
+<body>
+<nav><a href="/">Home</a> | <a href="/about">About</a></nav>
+<div role="navigation">Skip to content</div>
+<h1>Getting started</h1>
+<p>Read the <a href="https://example.com/guide">setup guide</a> first.
+It covers <b>everything</b> you&#39;ll need.</p>
+<pre><code>go get github.com/pteichman/go.cobe</code></pre>
+<table><tr><td>cell</td></tr></table>
+<ul><li>One item</li><li>Another<br>line</li></ul>
+<script>alert("hi")</script>
+<footer>Copyright</footer>
+</body></html>`
+
+	var lines []string
+	err := learnHTML(strings.NewReader(doc), func(s string) {
+		lines = append(lines, s)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{
+		"Getting started",
+		"Read the setup guide first.",
+		"It covers everything you'll need.",
+		"One item",
+		"Another",
+		"line",
+	}
+
+	if !reflect.DeepEqual(lines, expected) {
+		t.Errorf("Expected %q, got %q", expected, lines)
+	}
+}
+
+func TestLearnMarkdown(t *testing.T) {
+	doc := "---\ntitle: README\n---\n\n" +
+		"# go.cobe #\n\n" +
+		"This is a [Go](https://golang.org/) port of **cobe**, a\n" +
+		"Markov chain _chat bot_. See snake_case_names and the [docs][1].\n\n" +
+		"```sh\n$ go get github.com/pteichman/go.cobe\n```\n\n" +
+		"    indented code\n\n" +
+		"| command | purpose |\n|---|:---:|\n| learn | learn text |\n\n" +
+		"Usage\n-----\n\n" +
+		"- Learn with `cobe learn`.\n" +
+		"- Chat! ![logo](logo.png)\n\n" +
+		"> Quoted <em>text</em> stays.\n\n" +
+		"<div align=\"center\">\n<img src=\"x.png\">\n</div>\n\n" +
+		"[1]: https://example.com/docs\n"
+
+	var lines []string
+	err := learnMarkdown(strings.NewReader(doc), func(s string) {
+		lines = append(lines, s)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{
+		"go.cobe",
+		"This is a Go port of cobe, a Markov chain chat bot.",
+		"See snake_case_names and the docs.",
+		"Usage",
+		"Learn with cobe learn.",
+		"Chat!",
+		"Quoted text stays.",
+	}
+
+	if !reflect.DeepEqual(lines, expected) {
+		t.Errorf("Expected %q, got %q", expected, lines)
+	}
+}
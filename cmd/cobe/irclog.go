@@ -0,0 +1,243 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	cobe "github.com/pteichman/go.cobe"
+	"github.com/pteichman/go.cobe/ircbot"
+)
+
+// Message lines in each supported IRC log format, capturing the time,
+// nick and message. Joins, parts, modes, actions and notices don't
+// match these, so they're skipped.
+var ircLogFormats = map[string]*regexp.Regexp{
+	// 12:34 <@nick> message
+	"irssi": regexp.MustCompile(
+		`^(\d\d:\d\d(?::\d\d)?)\s+<\s?[~&@%+]?([^>\s]+)>\s(.*)$`),
+
+	// 2014-03-01 12:34:56<tab>@nick<tab>message
+	"weechat": regexp.MustCompile(
+		`^(\d{4}-\d\d-\d\d \d\d:\d\d:\d\d)\t[~&@%+]?([^\s*<>-][^\s]*)\t(.*)$`),
+
+	// [12:34:56] <nick> message
+	"znc": regexp.MustCompile(
+		`^\[(\d\d:\d\d(?::\d\d)?)\] <[~&@%+]?([^>\s]+)> (.*)$`),
+}
+
+// Layouts of the times captured by ircLogFormats.
+var ircLogTimeLayouts = []string{"2006-01-02 15:04:05", "15:04:05", "15:04"}
+
+// irssi marks the date when a log is opened and at midnight.
+var (
+	ircLogOpenedRegexp = regexp.MustCompile(
+		`^--- Log opened \w{3} (\w{3} \d\d \d\d:\d\d:\d\d \d{4})$`)
+	ircLogDayRegexp = regexp.MustCompile(
+		`^--- Day changed \w{3} (\w{3} \d\d \d{4})$`)
+)
+
+type ircLogOptions struct {
+	// Format is irssi, weechat, znc or auto to try them all.
+	Format string
+
+	// Ignore skips messages from these nicks, e.g. bots.
+	Ignore []string
+
+	// Only, if set, learns messages from these nicks alone.
+	Only []string
+
+	// Channel is recorded as the channel of each message.
+	Channel string
+
+	// Day is the date of logs that only stamp times, like ZNC's
+	// daily files, until the log marks a date itself.
+	Day time.Time
+}
+
+// learnIrcLog learns the channel messages in an IRC log, stripping
+// any "nick: " addressing the same way the ircbot does. Each message
+// is learned with its nick as the author and, if the log's date is
+// known, the time it was sent.
+func learnIrcLog(r io.Reader, opts *ircLogOptions, learn func(cobe.LearnRecord)) error {
+	var formats []*regexp.Regexp
+	if opts.Format == "auto" {
+		for _, re := range ircLogFormats {
+			formats = append(formats, re)
+		}
+	} else {
+		re, ok := ircLogFormats[opts.Format]
+		if !ok {
+			return fmt.Errorf("unknown irc log format: %s", opts.Format)
+		}
+		formats = append(formats, re)
+	}
+
+	day := opts.Day
+
+	s := bufio.NewScanner(bufio.NewReader(r))
+	for s.Scan() {
+		if d, ok := parseIrcLogDay(s.Text()); ok {
+			day = d
+			continue
+		}
+
+		stamp, nick, msg, ok := parseIrcLogLine(s.Text(), formats)
+		if !ok {
+			continue
+		}
+
+		if inFold(opts.Ignore, nick) {
+			continue
+		}
+
+		if len(opts.Only) > 0 && !inFold(opts.Only, nick) {
+			continue
+		}
+
+		_, msg = ircbot.SplitAddressee(msg)
+
+		// Skip commands meant for bots.
+		if msg == "" || strings.HasPrefix(msg, "!") {
+			continue
+		}
+
+		learn(cobe.LearnRecord{
+			Text:      msg,
+			Author:    nick,
+			Channel:   opts.Channel,
+			Timestamp: ircLogTime(day, stamp),
+		})
+	}
+
+	return s.Err()
+}
+
+func parseIrcLogLine(line string, formats []*regexp.Regexp) (stamp, nick, msg string, ok bool) {
+	line = strings.TrimRight(line, "\r")
+
+	for _, re := range formats {
+		groups := re.FindStringSubmatch(line)
+		if len(groups) > 0 {
+			return groups[1], groups[2], groups[3], true
+		}
+	}
+
+	return "", "", "", false
+}
+
+// parseIrcLogDay returns the date marked by an irssi log line.
+func parseIrcLogDay(line string) (time.Time, bool) {
+	line = strings.TrimRight(line, "\r")
+
+	if groups := ircLogOpenedRegexp.FindStringSubmatch(line); groups != nil {
+		t, err := time.ParseInLocation("Jan 02 15:04:05 2006", groups[1], time.Local)
+		return t, err == nil
+	}
+
+	if groups := ircLogDayRegexp.FindStringSubmatch(line); groups != nil {
+		t, err := time.ParseInLocation("Jan 02 2006", groups[1], time.Local)
+		return t, err == nil
+	}
+
+	return time.Time{}, false
+}
+
+// ircLogTime returns the time of a message stamped stamp on day. It's
+// zero if stamp has no date and day isn't known.
+func ircLogTime(day time.Time, stamp string) time.Time {
+	for _, layout := range ircLogTimeLayouts {
+		t, err := time.ParseInLocation(layout, stamp, time.Local)
+		if err != nil {
+			continue
+		}
+
+		if t.Year() != 0 {
+			return t
+		}
+
+		if day.IsZero() {
+			return time.Time{}
+		}
+
+		y, m, d := day.Date()
+		return time.Date(y, m, d, t.Hour(), t.Minute(), t.Second(), 0, time.Local)
+	}
+
+	return time.Time{}
+}
+
+var (
+	ircLogFileDayRegexp    = regexp.MustCompile(`(\d{4})-?(\d\d)-?(\d\d)`)
+	ircLogChannelDayRegexp = regexp.MustCompile(`_\d{8}$`)
+)
+
+// ircLogFileDay guesses the date of a log from its file name, as in
+// ZNC's network_#channel_20140301.log or #channel/2014-03-01.log.
+func ircLogFileDay(path string) time.Time {
+	groups := ircLogFileDayRegexp.FindStringSubmatch(filepath.Base(path))
+	if groups == nil {
+		return time.Time{}
+	}
+
+	t, err := time.ParseInLocation("20060102", groups[1]+groups[2]+groups[3], time.Local)
+	if err != nil {
+		return time.Time{}
+	}
+
+	return t
+}
+
+// ircLogFileChannel guesses the channel of a log from its file name,
+// or the name of the directory it's in: irssi's #channel.log,
+// weechat's irc.network.#channel.weechatlog and ZNC's
+// network_#channel_20140301.log or #channel/2014-03-01.log.
+func ircLogFileChannel(path string) string {
+	base := filepath.Base(path)
+	base = strings.TrimSuffix(base, filepath.Ext(base))
+
+	for _, name := range []string{base, filepath.Base(filepath.Dir(path))} {
+		i := strings.IndexAny(name, "#&")
+		if i < 0 {
+			continue
+		}
+
+		return ircLogChannelDayRegexp.ReplaceAllString(name[i:], "")
+	}
+
+	return ""
+}
+
+// inFold reports whether needle is in haystack, ignoring case like
+// IRC nicks do.
+func inFold(haystack []string, needle string) bool {
+	for _, h := range haystack {
+		if strings.EqualFold(h, needle) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// stringsFlag is a flag.Value that collects each use of a flag, also
+// splitting on commas.
+type stringsFlag []string
+
+func (f *stringsFlag) String() string {
+	return strings.Join(*f, ",")
+}
+
+func (f *stringsFlag) Set(value string) error {
+	for _, s := range strings.Split(value, ",") {
+		if s != "" {
+			*f = append(*f, s)
+		}
+	}
+
+	return nil
+}
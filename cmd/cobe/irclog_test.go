@@ -0,0 +1,161 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	cobe "github.com/pteichman/go.cobe"
+)
+
+func TestLearnIrcLog(t *testing.T) {
+	var tests = []struct {
+		format   string
+		log      string
+		opts     ircLogOptions
+		expected []string
+	}{
+		{"irssi", `--- Log opened Sat Mar 01 12:00:00 2014
+12:00 -!- alice [~alice@example.com] has joined #cobe
+12:01 <@alice> hello there
+12:02 < bob> cobe: how are you?
+12:03  * bob waves
+12:04 -!- mode/#cobe [+o bob] by alice
+12:05 <+bot> !weather boston
+12:06 <bot> It is sunny.
+12:07 -!- bob [~bob@example.com] has quit [Quit: bye]
+`, ircLogOptions{Ignore: []string{"BOT"}},
+			[]string{"hello there", "how are you?"}},
+
+		{"weechat", "2014-03-01 12:00:00\t-->\talice (~alice@example.com) has joined #cobe\n" +
+			"2014-03-01 12:01:00\t@alice\thello there\n" +
+			"2014-03-01 12:02:00\tbob\tcobe, see http://example.com/\n" +
+			"2014-03-01 12:03:00\t *\tbob waves\n" +
+			"2014-03-01 12:04:00\t<--\tbob (~bob@example.com) has quit\n" +
+			"2014-03-01 12:05:00\t--\tMode #cobe [+o bob] by alice\n",
+			ircLogOptions{},
+			[]string{"hello there", "see http://example.com/"}},
+
+		{"znc", "[12:00:00] *** Joins: alice (~alice@example.com)\r\n" +
+			"[12:01:00] <alice> hello there\r\n" +
+			"[12:02:00] <bob> http://example.com/ is down\r\n" +
+			"[12:03:00] * bob waves\r\n" +
+			"[12:04:00] *** bob sets mode: +o alice\r\n",
+			ircLogOptions{Only: []string{"bob"}},
+			[]string{"http://example.com/ is down"}},
+	}
+
+	for ti, tt := range tests {
+		for _, format := range []string{tt.format, "auto"} {
+			opts := tt.opts
+			opts.Format = format
+
+			var lines []string
+			err := learnIrcLog(strings.NewReader(tt.log), &opts, func(rec cobe.LearnRecord) {
+				lines = append(lines, rec.Text)
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if strings.Join(lines, "|") != strings.Join(tt.expected, "|") {
+				t.Errorf("[%d] %s: expected %q, was %q", ti, format,
+					tt.expected, lines)
+			}
+		}
+	}
+}
+
+func TestIrcLogRecords(t *testing.T) {
+	day := func(y int, m time.Month, d, h, min int) time.Time {
+		return time.Date(y, m, d, h, min, 0, 0, time.Local)
+	}
+
+	var tests = []struct {
+		log      string
+		opts     ircLogOptions
+		expected []cobe.LearnRecord
+	}{
+		{"--- Log opened Sat Mar 01 12:00:00 2014\n" +
+			"23:59 <@alice> late\n" +
+			"--- Day changed Sun Mar 02 2014\n" +
+			"00:01 < bob> early\n",
+			ircLogOptions{Channel: "#cobe"},
+			[]cobe.LearnRecord{
+				{Text: "late", Author: "alice", Channel: "#cobe", Timestamp: day(2014, 3, 1, 23, 59)},
+				{Text: "early", Author: "bob", Channel: "#cobe", Timestamp: day(2014, 3, 2, 0, 1)},
+			}},
+
+		{"2014-03-01 12:01:00\t@alice\thello\n",
+			ircLogOptions{},
+			[]cobe.LearnRecord{
+				{Text: "hello", Author: "alice", Timestamp: day(2014, 3, 1, 12, 1)},
+			}},
+
+		// ZNC logs only have times; the date comes from Day, if known.
+		{"[12:01:00] <alice> hello\n",
+			ircLogOptions{Day: day(2014, 3, 1, 0, 0)},
+			[]cobe.LearnRecord{
+				{Text: "hello", Author: "alice", Timestamp: day(2014, 3, 1, 12, 1)},
+			}},
+		{"[12:01:00] <alice> hello\n",
+			ircLogOptions{},
+			[]cobe.LearnRecord{{Text: "hello", Author: "alice"}},
+		},
+	}
+
+	for ti, tt := range tests {
+		opts := tt.opts
+		opts.Format = "auto"
+
+		var recs []cobe.LearnRecord
+		err := learnIrcLog(strings.NewReader(tt.log), &opts, func(rec cobe.LearnRecord) {
+			recs = append(recs, rec)
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(recs) != len(tt.expected) {
+			t.Errorf("[%d] expected %v, was %v", ti, tt.expected, recs)
+			continue
+		}
+
+		for i, rec := range recs {
+			e := tt.expected[i]
+			if rec.Text != e.Text || rec.Author != e.Author ||
+				rec.Channel != e.Channel || !rec.Timestamp.Equal(e.Timestamp) {
+				t.Errorf("[%d] expected %+v, was %+v", ti, e, rec)
+			}
+		}
+	}
+}
+
+func TestIrcLogFile(t *testing.T) {
+	var tests = []struct {
+		path    string
+		channel string
+		day     string
+	}{
+		{"irclogs/freenode/#cobe.log", "#cobe", ""},
+		{"logs/irc.freenode.#cobe.weechatlog", "#cobe", ""},
+		{"moddata/log/freenode_#cobe_20140301.log", "#cobe", "2014-03-01"},
+		{"moddata/log/freenode/#cobe/2014-03-01.log", "#cobe", "2014-03-01"},
+		{"notes.txt", "", ""},
+	}
+
+	for ti, tt := range tests {
+		if channel := ircLogFileChannel(tt.path); channel != tt.channel {
+			t.Errorf("[%d] %s: expected channel %q, was %q", ti, tt.path, tt.channel, channel)
+		}
+
+		var day string
+		if d := ircLogFileDay(tt.path); !d.IsZero() {
+			day = d.Format("2006-01-02")
+		}
+
+		if day != tt.day {
+			t.Errorf("[%d] %s: expected day %q, was %q", ti, tt.path, tt.day, day)
+		}
+	}
+}
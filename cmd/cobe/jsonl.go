@@ -0,0 +1,31 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+
+	cobe "github.com/pteichman/go.cobe"
+)
+
+// learnJSONLine learns a line of a file with one JSON record per
+// line, in the form read by cobe.LearnRecord. It's the jsonl
+// lineFormat, so learn sessions can checkpoint between records and
+// log bad records with their line numbers. Blank lines are ignored.
+func learnJSONLine(line string, opts *learnOptions, learn func(cobe.LearnRecord)) error {
+	line = strings.TrimSpace(line)
+	if line == "" {
+		return nil
+	}
+
+	var rec cobe.LearnRecord
+	err := json.Unmarshal([]byte(line), &rec)
+	if err != nil {
+		return err
+	}
+
+	if opts.allow(rec.Channel, rec.Author) {
+		learn(rec)
+	}
+
+	return nil
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	cobe "github.com/pteichman/go.cobe"
+)
+
+const testJSONLines = `{"text": "hello there", "author": "alice", "channel": "#cobe"}
+
+{"text": "missing a brace"
+{"author": "bob"}
+{"text": "how are you?", "author": "bob", "id": 42}
+`
+
+// readJSONLines reads records the way a learn session does.
+func readJSONLines(t *testing.T, data string, opts learnOptions) []cobe.LearnRecord {
+	var recs []cobe.LearnRecord
+	err := readLines(strings.NewReader(data), "records.jsonl", newCheckpoint(),
+		learnJSONLine, &opts, func(rec cobe.LearnRecord) {
+			recs = append(recs, rec)
+		}, func(string) error { return nil })
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return recs
+}
+
+func TestLearnJSONLines(t *testing.T) {
+	var tests = []struct {
+		opts     learnOptions
+		expected []string
+	}{
+		{learnOptions{}, []string{"hello there", "how are you?"}},
+		{learnOptions{Users: []string{"bob"}}, []string{"how are you?"}},
+		{learnOptions{Channels: []string{"#cobe"}}, []string{"hello there"}},
+	}
+
+	for ti, tt := range tests {
+		var got []string
+		for _, rec := range readJSONLines(t, testJSONLines, tt.opts) {
+			got = append(got, rec.Text)
+		}
+
+		if !reflect.DeepEqual(got, tt.expected) {
+			t.Errorf("[%d] expected %q, got %q", ti, tt.expected, got)
+		}
+	}
+
+	recs := readJSONLines(t, testJSONLines, learnOptions{})
+
+	if recs[0].Author != "alice" || recs[0].Channel != "#cobe" {
+		t.Errorf("Expected alice in #cobe, got %+v", recs[0])
+	}
+
+	if string(recs[1].Extra["id"]) != "42" {
+		t.Errorf("Expected extra id 42, got %v", recs[1].Extra)
+	}
+}
+
+func TestLearnJSONLinesResume(t *testing.T) {
+	dir := writeFiles(t, map[string]string{"records.jsonl": testJSONLines})
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "records.jsonl")
+
+	b, err := cobe.OpenCobe2Brain(filepath.Join(dir, "test.brain"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Close()
+
+	session := func(resume bool) *learnSession {
+		return &learnSession{
+			brain:    b,
+			opts:     &learnOptions{},
+			batch:    2,
+			resume:   resume,
+			progress: newLearnProgress(ioutil.Discard),
+		}
+	}
+
+	s := session(false)
+	err = s.learnFile(path, "jsonl")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if s.progress.lines != 2 {
+		t.Errorf("Expected 2 records learned, got %d", s.progress.lines)
+	}
+
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString(`{"text": "one more", "author": "carol"}` + "\n")
+	f.Close()
+
+	s = session(true)
+	err = s.learnFile(path, "jsonl")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if s.progress.lines != 1 {
+		t.Errorf("Expected 1 record learned on resume, got %d", s.progress.lines)
+	}
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	cobe "github.com/pteichman/go.cobe"
+)
+
+// A learnFormat reads the file or directory at path and calls learn
+// with each line of text in it that's worth learning, along with
+// whatever the format records about its author and channel.
+type learnFormat func(path string, opts *learnOptions, learn func(cobe.LearnRecord)) error
+
+var learnFormats = map[string]learnFormat{
+	"gutenberg":  fileFormat(learnGutenberg),
+	"html":       fileFormat(learnHTML),
+	"markdown":   fileFormat(learnMarkdown),
+	"mbox":       learnMbox,
+	"srt":        fileFormat(learnSubtitles),
+	"vtt":        fileFormat(learnSubtitles),
+	"slack":      learnSlack,
+	"mattermost": learnMattermost,
+	"discord":    learnDiscord,
+}
+
+// A lineFormat parses one line of a file with a record on each line.
+// Files in these formats can be learned in batches and resumed part
+// way through.
+type lineFormat func(line string, opts *learnOptions, learn func(cobe.LearnRecord)) error
+
+var lineFormats = map[string]lineFormat{
+	"lines": learnLine,
+	"jsonl": learnJSONLine,
+}
+
+// learnOptions filters the messages learned from chat exports.
+type learnOptions struct {
+	Channels        []string
+	ExcludeChannels []string
+	Users           []string
+	ExcludeUsers    []string
+}
+
+// allow reports whether a message from user in channel passes the
+// include and exclude filters.
+func (o *learnOptions) allow(channel, user string) bool {
+	if len(o.Channels) > 0 && !inFold(o.Channels, channel) {
+		return false
+	}
+
+	if len(o.Users) > 0 && !inFold(o.Users, user) {
+		return false
+	}
+
+	return !inFold(o.ExcludeChannels, channel) && !inFold(o.ExcludeUsers, user)
+}
+
+func learnFormatNames() []string {
+	var names []string
+	for name := range learnFormats {
+		names = append(names, name)
+	}
+
+	for name := range lineFormats {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+	return names
+}
+
+// fileFormat adapts a reader of plain text files to a learnFormat.
+func fileFormat(read func(io.Reader, func(string)) error) learnFormat {
+	return func(path string, opts *learnOptions, learn func(cobe.LearnRecord)) error {
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		return read(f, func(text string) {
+			learn(cobe.LearnRecord{Text: text})
+		})
+	}
+}
+
+func learnLine(line string, opts *learnOptions, learn func(cobe.LearnRecord)) error {
+	learn(cobe.LearnRecord{Text: line})
+	return nil
+}
+
+// learnFile learns path in the named format.
+func (s *learnSession) learnFile(path string, format string) error {
+	if parse, ok := lineFormats[format]; ok {
+		return s.learnLines(path, parse)
+	}
+
+	read, ok := learnFormats[format]
+	if !ok {
+		return fmt.Errorf("unknown format: %s (expected %s)", format,
+			strings.Join(learnFormatNames(), ", "))
+	}
+
+	return s.learnWhole(path, read)
+}
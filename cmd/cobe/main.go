@@ -6,7 +6,11 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"regexp"
 	"runtime/pprof"
+	"strconv"
+	"strings"
+	"time"
 )
 
 import (
@@ -22,26 +26,114 @@ var (
 	ircserver  = flag.String("irc.server", "", "irc server (host:port)")
 	ircchannel = flag.String("irc.channels", "#cobe", "irc channels")
 	ircnick    = flag.String("irc.nick", "cobe", "irc nickname")
+	ircadmins  = flag.String("irc.admins", "",
+		"irc nicknames allowed admin commands, comma separated")
+	ircdecay = flag.Duration("irc.decay", 0,
+		"how often to run decay maintenance (see set-decay)")
 )
 
+var scrub = flag.String("scrub", "", "personal data in learned text: "+
+	"off, replace or drop (default replace for ircbot, otherwise off)")
+
+var (
+	moderation = flag.String("moderation", "",
+		"blocklist file of words to keep out of replies and learning")
+	moderationMask = flag.Bool("moderation.mask", false,
+		"mask blocked words in replies instead of rejecting them")
+)
+
+var novel = flag.Bool("novel", false,
+	"reject replies that copy learned sentences (see set-novelty)")
+
 var (
 	statsdserver = flag.String("statsd.server", "", "statsd server (host:port)")
 	statsdname   = flag.String("statsd.name", "cobe", "statsd name")
 )
 
-func learnFileLines(b *cobe.Cobe2Brain, path string) error {
+func readModerator(path string) (*cobe.Moderator, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	m, err := cobe.NewModerator(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", path, err)
+	}
+
+	return m, nil
+}
+
+func learnIrcLogFile(b *cobe.Cobe2Brain, path string, opts *ircLogOptions) error {
 	f, err := os.Open(path)
 	if err != nil {
 		return err
 	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	progress := newLearnProgress(os.Stderr)
+	progress.startFile(path, info.Size(), 0)
+	defer progress.endFile()
+
+	fileOpts := *opts
+	if fileOpts.Channel == "" {
+		fileOpts.Channel = ircLogFileChannel(path)
+	}
+
+	if fileOpts.Day.IsZero() {
+		fileOpts.Day = ircLogFileDay(path)
+	}
+
+	r := &progressReader{f, progress}
+	return learnIrcLog(r, &fileOpts, func(rec cobe.LearnRecord) {
+		b.LearnRecord(rec)
+		progress.learned()
+	})
+}
+
+// readBlocklist reads regexps from a file, one per line. Blank lines
+// and lines starting with # are ignored.
+func readBlocklist(path string) ([]*regexp.Regexp, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var ret []*regexp.Regexp
+
+	s := bufio.NewScanner(f)
+	for n := 1; s.Scan(); n++ {
+		line := strings.TrimSpace(s.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		re, err := regexp.Compile(line)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %s", path, n, err)
+		}
+
+		ret = append(ret, re)
+	}
+
+	return ret, s.Err()
+}
 
-	s := bufio.NewScanner(bufio.NewReader(f))
-	for s.Scan() {
-		fmt.Println(s.Text())
-		b.Learn(s.Text())
+func importSynonyms(b *cobe.Cobe2Brain, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
 	}
+	defer f.Close()
 
-	return nil
+	return b.ImportSynonyms(f)
 }
 
 func main() {
@@ -76,6 +168,29 @@ func main() {
 		log.Fatalf("Opening brain file: %s", err)
 	}
 
+	var scrubMode cobe.ScrubMode
+	if *scrub != "" {
+		var ok bool
+		scrubMode, ok = cobe.ParseScrubMode(*scrub)
+		if !ok {
+			log.Fatalf("Unknown -scrub mode: %s", *scrub)
+		}
+		b.SetScrub(scrubMode)
+	}
+
+	if *moderation != "" {
+		m, err := readModerator(*moderation)
+		if err != nil {
+			log.Fatalf("Reading moderation blocklist: %s", err)
+		}
+
+		m.Mask = *moderationMask
+		b.ModerateReplies(m)
+		b.ModerateLearn(m)
+	}
+
+	b.SetRequireNovel(*novel)
+
 	var cmd = args[0]
 	switch {
 	case cmd == "console":
@@ -85,11 +200,101 @@ func main() {
 			Server:   *ircserver,
 			Nick:     *ircnick,
 			Channels: []string{*ircchannel},
+			Scrub:    scrubMode,
+			Decay:    *ircdecay,
+		}
+		if *ircadmins != "" {
+			opts.Admins = strings.Split(*ircadmins, ",")
 		}
 		ircbot.RunForever(b, opts)
 	case cmd == "learn":
-		for _, f := range args[1:] {
-			learnFileLines(b, f)
+		var opts learnOptions
+
+		fs := flag.NewFlagSet("learn", flag.ExitOnError)
+		format := fs.String("format", "lines", "input format: "+
+			strings.Join(learnFormatNames(), ", "))
+		sentences := fs.Bool("sentences", false,
+			"learn each sentence of a line separately")
+		resume := fs.Bool("resume", false,
+			"continue each file from its last checkpoint")
+		batch := fs.Int("batch", 1000,
+			"lines to learn between checkpoints (lines and jsonl formats)")
+		minTokens := fs.Int("min-tokens", 0,
+			"skip lines with fewer tokens")
+		maxTokens := fs.Int("max-tokens", 0,
+			"skip lines with more tokens (0 for no limit)")
+		maxNonWords := fs.Float64("max-nonword-ratio", 1,
+			"skip lines where more than this fraction of tokens aren't words")
+		blocklist := fs.String("blocklist", "",
+			"skip lines matching any regexp in this file, one per line")
+		fs.Var((*stringsFlag)(&opts.Channels), "channel",
+			"only learn chat messages from these channels (repeatable)")
+		fs.Var((*stringsFlag)(&opts.ExcludeChannels), "exclude-channel",
+			"ignore chat messages from these channels (repeatable)")
+		fs.Var((*stringsFlag)(&opts.Users), "user",
+			"only learn chat messages from these users (repeatable)")
+		fs.Var((*stringsFlag)(&opts.ExcludeUsers), "exclude-user",
+			"ignore chat messages from these users (repeatable)")
+		fs.Parse(args[1:])
+
+		if *batch < 1 {
+			log.Fatal("-batch must be at least 1")
+		}
+
+		if *minTokens > 0 {
+			b.AddLearnFilter(cobe.MinTokens(*minTokens))
+		}
+
+		if *maxTokens > 0 {
+			b.AddLearnFilter(cobe.MaxTokens(*maxTokens))
+		}
+
+		if *maxNonWords < 1 {
+			b.AddLearnFilter(cobe.MaxNonWordRatio(*maxNonWords))
+		}
+
+		if *blocklist != "" {
+			patterns, err := readBlocklist(*blocklist)
+			if err != nil {
+				log.Fatalf("Reading blocklist: %s", err)
+			}
+			b.AddLearnFilter(cobe.Blocklist(patterns...))
+		}
+
+		s := &learnSession{
+			brain:    b,
+			opts:     &opts,
+			batch:    *batch,
+			resume:   *resume,
+			progress: newLearnProgress(os.Stderr),
+		}
+
+		b.SetSplitSentences(*sentences)
+		for _, f := range fs.Args() {
+			err := s.learnFile(f, *format)
+			if err != nil {
+				log.Fatalf("Learning %s: %s", f, err)
+			}
+		}
+	case cmd == "learn-irc-log":
+		var opts ircLogOptions
+
+		fs := flag.NewFlagSet("learn-irc-log", flag.ExitOnError)
+		fs.StringVar(&opts.Format, "format", "auto",
+			"log format: irssi, weechat, znc or auto")
+		fs.Var((*stringsFlag)(&opts.Ignore), "ignore-nick",
+			"ignore messages from these nicks (repeatable)")
+		fs.Var((*stringsFlag)(&opts.Only), "only-nick",
+			"only learn messages from these nicks (repeatable)")
+		fs.StringVar(&opts.Channel, "channel", "",
+			"channel the logs are from (default guessed from each file name)")
+		fs.Parse(args[1:])
+
+		for _, f := range fs.Args() {
+			err := learnIrcLogFile(b, f, &opts)
+			if err != nil {
+				log.Fatalf("Learning %s: %s", f, err)
+			}
 		}
 	case cmd == "del-stemmer":
 		err := b.DelStemmer()
@@ -98,12 +303,141 @@ func main() {
 		}
 	case cmd == "set-stemmer":
 		if len(args) < 2 {
-			log.Fatal("Usage: set-stemmer <language>")
+			log.Fatal("Usage: set-stemmer <name>")
 		}
 		err := b.SetStemmer(args[1])
 		if err != nil {
 			log.Fatalf("Setting stemmer: %s", err)
 		}
+	case cmd == "set-languages":
+		if len(args) < 2 {
+			log.Fatalf("Usage: set-languages <language>... (from %s)",
+				strings.Join(cobe.Languages(), ", "))
+		}
+		err := b.SetLanguages(args[1:]...)
+		if err != nil {
+			log.Fatalf("Setting languages: %s", err)
+		}
+	case cmd == "del-casefold":
+		err := b.DelCaseFold()
+		if err != nil {
+			log.Fatalf("Deleting case folding: %s", err)
+		}
+	case cmd == "set-casefold":
+		err := b.SetCaseFold()
+		if err != nil {
+			log.Fatalf("Setting case folding: %s", err)
+		}
+	case cmd == "del-fuzzy":
+		err := b.DelFuzzy()
+		if err != nil {
+			log.Fatalf("Deleting fuzzy matching: %s", err)
+		}
+	case cmd == "set-fuzzy":
+		if len(args) < 2 {
+			log.Fatal("Usage: set-fuzzy <max distance>")
+		}
+		dist, err := strconv.Atoi(args[1])
+		if err != nil {
+			log.Fatalf("Parsing distance: %s", err)
+		}
+		err = b.SetFuzzy(dist)
+		if err != nil {
+			log.Fatalf("Setting fuzzy matching: %s", err)
+		}
+	case cmd == "del-dedupe":
+		err := b.DelDedupe()
+		if err != nil {
+			log.Fatalf("Deleting dedupe: %s", err)
+		}
+	case cmd == "set-dedupe":
+		if len(args) < 2 {
+			log.Fatal("Usage: set-dedupe <lines>")
+		}
+		n, err := strconv.Atoi(args[1])
+		if err != nil {
+			log.Fatalf("Parsing lines: %s", err)
+		}
+		err = b.SetDedupe(n)
+		if err != nil {
+			log.Fatalf("Setting dedupe: %s", err)
+		}
+	case cmd == "del-novelty":
+		err := b.DelNovelty()
+		if err != nil {
+			log.Fatalf("Deleting novelty: %s", err)
+		}
+	case cmd == "set-novelty":
+		if len(args) < 2 {
+			log.Fatal("Usage: set-novelty <tokens>")
+		}
+		n, err := strconv.Atoi(args[1])
+		if err != nil {
+			log.Fatalf("Parsing tokens: %s", err)
+		}
+		err = b.SetNovelty(n)
+		if err != nil {
+			log.Fatalf("Setting novelty: %s", err)
+		}
+	case cmd == "decay":
+		err := b.Decay()
+		if err != nil {
+			log.Fatalf("Decaying: %s", err)
+		}
+	case cmd == "del-decay":
+		err := b.DelDecay()
+		if err != nil {
+			log.Fatalf("Deleting decay: %s", err)
+		}
+	case cmd == "set-decay":
+		if len(args) < 2 {
+			log.Fatal("Usage: set-decay <half-life, like 720h>")
+		}
+		d, err := time.ParseDuration(args[1])
+		if err != nil {
+			log.Fatalf("Parsing half-life: %s", err)
+		}
+		err = b.SetDecay(d)
+		if err != nil {
+			log.Fatalf("Setting decay: %s", err)
+		}
+	case cmd == "del-provenance":
+		err := b.DelProvenance()
+		if err != nil {
+			log.Fatalf("Deleting provenance: %s", err)
+		}
+	case cmd == "set-provenance":
+		err := b.SetProvenance()
+		if err != nil {
+			log.Fatalf("Setting provenance: %s", err)
+		}
+	case cmd == "forget-author":
+		if len(args) < 2 {
+			log.Fatal("Usage: forget-author <name>")
+		}
+		n, err := b.ForgetAuthor(args[1])
+		if err != nil {
+			log.Fatalf("Forgetting author: %s", err)
+		}
+		fmt.Printf("Forgot %d lines from %s\n", n, args[1])
+	case cmd == "migrate":
+		err := b.Migrate()
+		if err != nil {
+			log.Fatalf("Migrating: %s", err)
+		}
+	case cmd == "del-synonyms":
+		err := b.DelSynonyms()
+		if err != nil {
+			log.Fatalf("Deleting synonyms: %s", err)
+		}
+	case cmd == "import-synonyms":
+		if len(args) < 2 {
+			log.Fatal("Usage: import-synonyms <file>")
+		}
+		err := importSynonyms(b, args[1])
+		if err != nil {
+			log.Fatalf("Importing synonyms: %s", err)
+		}
 	default:
 		log.Fatalf("Unknown command: %s", cmd)
 	}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"bufio"
+	"io"
+	"regexp"
+	"strings"
+
+	cobe "github.com/pteichman/go.cobe"
+)
+
+// learnMarkdown learns the sentences in the prose of a Markdown
+// document. Front matter, code blocks, tables and raw HTML blocks are
+// skipped, and inline markup is removed so links are learned by their
+// text alone.
+func learnMarkdown(r io.Reader, learn func(string)) error {
+	paragraphs, err := markdownParagraphs(r)
+	if err != nil {
+		return err
+	}
+
+	for _, p := range paragraphs {
+		for _, s := range cobe.SplitSentences(p) {
+			learn(s)
+		}
+	}
+
+	return nil
+}
+
+var (
+	mdFenceRegexp     = regexp.MustCompile("^ {0,3}(```|~~~)")
+	mdHeadingRegexp   = regexp.MustCompile(`^ {0,3}#{1,6}(\s+|$)`)
+	mdRuleRegexp      = regexp.MustCompile(`^ {0,3}([-*_=])(\s*([-*_=]))+\s*$`)
+	mdListRegexp      = regexp.MustCompile(`^\s*([-*+]|\d+[.)])\s+`)
+	mdQuoteRegexp     = regexp.MustCompile(`^\s*(>\s?)+`)
+	mdTableRuleRegexp = regexp.MustCompile(`^\s*\|?(\s*:?-+:?\s*\|)+\s*(:?-+:?\s*)?$`)
+	mdRefDefRegexp    = regexp.MustCompile(`^ {0,3}\[[^\]]+\]:\s`)
+	mdHTMLRegexp      = regexp.MustCompile(`^ {0,3}</?[A-Za-z!]`)
+)
+
+func markdownParagraphs(r io.Reader) ([]string, error) {
+	var lines []string
+
+	s := bufio.NewScanner(bufio.NewReader(r))
+	for s.Scan() {
+		lines = append(lines, strings.TrimRight(s.Text(), " \t\r"))
+	}
+
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+
+	var paragraphs []string
+	var cur []string
+
+	flush := func() {
+		if p := markdownInline(strings.Join(cur, " ")); p != "" {
+			paragraphs = append(paragraphs, p)
+		}
+		cur = nil
+	}
+
+	i := 0
+
+	// YAML front matter.
+	if len(lines) > 0 && lines[0] == "---" {
+		for i = 1; i < len(lines) && lines[i] != "---" && lines[i] != "..."; i++ {
+		}
+		i++
+	}
+
+	for ; i < len(lines); i++ {
+		line := lines[i]
+
+		switch {
+		case mdFenceRegexp.MatchString(line):
+			flush()
+			fence := strings.TrimSpace(line)[:3]
+			for i++; i < len(lines) && !strings.HasPrefix(strings.TrimSpace(lines[i]), fence); i++ {
+			}
+		case strings.TrimSpace(line) == "":
+			flush()
+		case len(cur) == 0 && (strings.HasPrefix(line, "    ") || strings.HasPrefix(line, "\t")) &&
+			!mdListRegexp.MatchString(line):
+			// Indented code block.
+		case i+1 < len(lines) && strings.Contains(line, "|") && mdTableRuleRegexp.MatchString(lines[i+1]):
+			flush()
+			for i += 2; i < len(lines) && strings.Contains(lines[i], "|"); i++ {
+			}
+			i--
+		case mdRuleRegexp.MatchString(line):
+			// A rule, or the underline of a setext heading.
+			flush()
+		case mdRefDefRegexp.MatchString(line):
+			flush()
+		case len(cur) == 0 && mdHTMLRegexp.MatchString(line):
+			// Raw HTML runs to the next blank line.
+			for ; i+1 < len(lines) && strings.TrimSpace(lines[i+1]) != ""; i++ {
+			}
+		case mdHeadingRegexp.MatchString(line):
+			flush()
+			cur = append(cur, strings.TrimRight(mdHeadingRegexp.ReplaceAllString(line, ""), " #"))
+			flush()
+		default:
+			line = mdQuoteRegexp.ReplaceAllString(line, "")
+			if mdListRegexp.MatchString(line) {
+				flush()
+				line = mdListRegexp.ReplaceAllString(line, "")
+			}
+
+			cur = append(cur, strings.TrimSpace(line))
+		}
+	}
+
+	flush()
+	return paragraphs, nil
+}
+
+var (
+	mdImageRegexp    = regexp.MustCompile(`!\[[^\]]*\](\([^)]*\)|\[[^\]]*\])`)
+	mdRefLinkRegexp  = regexp.MustCompile(`\[([^\]]*)\]\[[^\]]*\]`)
+	mdFootnoteRegexp = regexp.MustCompile(`\[\^[^\]]*\]`)
+	mdAutoLinkRegexp = regexp.MustCompile(`<(https?|mailto|ftp):[^>]*>`)
+	mdTagRegexp      = regexp.MustCompile(`</?[A-Za-z][^<>]*>`)
+	mdEmphRegexp     = regexp.MustCompile(`\*+|~~|(^|\W)_+|_+(\W|$)`)
+	mdEscapeRegexp   = regexp.MustCompile(`\\([!-/:-@\[-` + "`" + `{-~])`)
+)
+
+// markdownInline removes inline markup from a paragraph.
+func markdownInline(text string) string {
+	text = mdEscapeRegexp.ReplaceAllString(text, "$1")
+	text = mdImageRegexp.ReplaceAllString(text, "")
+	text = mdFootnoteRegexp.ReplaceAllString(text, "")
+	text = markdownLinkRegexp.ReplaceAllString(text, "$1")
+	text = mdRefLinkRegexp.ReplaceAllString(text, "$1")
+	text = mdAutoLinkRegexp.ReplaceAllString(text, "")
+	text = mdTagRegexp.ReplaceAllString(text, "")
+	text = strings.Replace(text, "`", "", -1)
+	text = mdEmphRegexp.ReplaceAllString(text, "$1$2")
+
+	return strings.Join(strings.Fields(text), " ")
+}
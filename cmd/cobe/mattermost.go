@@ -0,0 +1,128 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	cobe "github.com/pteichman/go.cobe"
+)
+
+// A line of a Mattermost bulk export. Only posts are learned.
+type mattermostLine struct {
+	Type       string          `json:"type"`
+	Post       *mattermostPost `json:"post"`
+	DirectPost *mattermostPost `json:"direct_post"`
+}
+
+type mattermostPost struct {
+	Channel        string                 `json:"channel"`
+	ChannelMembers []string               `json:"channel_members"`
+	User           string                 `json:"user"`
+	Message        string                 `json:"message"`
+	CreateAt       int64                  `json:"create_at"`
+	Type           string                 `json:"type"`
+	Props          map[string]interface{} `json:"props"`
+	Replies        []mattermostPost       `json:"replies"`
+}
+
+// learnMattermost learns the posts and replies in a Mattermost bulk
+// export (JSON Lines), or in every .jsonl file in a directory.
+func learnMattermost(path string, opts *learnOptions, learn func(cobe.LearnRecord)) error {
+	files := []string{path}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+
+	if info.IsDir() {
+		files, err = filepath.Glob(filepath.Join(path, "*.jsonl"))
+		if err != nil {
+			return err
+		}
+
+		// Learn in a stable order, whatever the directory gives.
+		sort.Strings(files)
+	}
+
+	for _, file := range files {
+		err = learnMattermostFile(file, opts, learn)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func learnMattermostFile(path string, opts *learnOptions, learn func(cobe.LearnRecord)) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	s := bufio.NewScanner(bufio.NewReader(f))
+	s.Buffer(nil, 16*1024*1024)
+
+	var n int
+	for s.Scan() {
+		n++
+
+		var line mattermostLine
+		err = json.Unmarshal(s.Bytes(), &line)
+		if err != nil {
+			return fmt.Errorf("%s:%d: %s", path, n, err)
+		}
+
+		post := line.Post
+		if line.Type == "direct_post" {
+			post = line.DirectPost
+		}
+
+		if post == nil {
+			continue
+		}
+
+		channel := post.Channel
+		if channel == "" {
+			channel = strings.Join(post.ChannelMembers, ",")
+		}
+
+		learnMattermostPost(post, channel, opts, learn)
+		for i := range post.Replies {
+			learnMattermostPost(&post.Replies[i], channel, opts, learn)
+		}
+	}
+
+	return s.Err()
+}
+
+func learnMattermostPost(post *mattermostPost, channel string, opts *learnOptions, learn func(cobe.LearnRecord)) {
+	// System messages (joins, header changes) have a system_ type.
+	if strings.HasPrefix(post.Type, "system_") {
+		return
+	}
+
+	if fromBot, _ := post.Props["from_bot"].(string); fromBot == "true" {
+		return
+	}
+
+	if !opts.allow(channel, post.User) {
+		return
+	}
+
+	var ts time.Time
+	if post.CreateAt > 0 {
+		// create_at is in milliseconds.
+		ts = time.Unix(0, post.CreateAt*int64(time.Millisecond)).UTC()
+	}
+
+	learnChatText(decodeMarkdown(post.Message), post.User, channel, ts, learn)
+}
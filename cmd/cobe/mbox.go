@@ -0,0 +1,240 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"io"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"os"
+	"regexp"
+	"strings"
+
+	cobe "github.com/pteichman/go.cobe"
+	"golang.org/x/text/encoding/htmlindex"
+)
+
+// learnMbox learns the sentences in the plain text body of each
+// message in an mbox file. Quoted text, attributions and signatures
+// are dropped, so each message contributes only what its author wrote.
+func learnMbox(path string, opts *learnOptions, learn func(cobe.LearnRecord)) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return readMbox(f, func(msg []byte) error {
+		m, err := mail.ReadMessage(bytes.NewReader(msg))
+		if err != nil {
+			// A damaged message shouldn't stop the whole archive.
+			return nil
+		}
+
+		var from string
+		if addr, err := mail.ParseAddress(m.Header.Get("From")); err == nil {
+			from = addr.Address
+		}
+
+		list := m.Header.Get("List-Id")
+		if !opts.allow(list, from) {
+			return nil
+		}
+
+		body, err := mailText(m.Header, m.Body)
+		if err != nil || body == "" {
+			return nil
+		}
+
+		date, _ := m.Header.Date()
+
+		for _, p := range mailParagraphs(body) {
+			for _, s := range cobe.SplitSentences(p) {
+				learn(cobe.LearnRecord{
+					Text:      s,
+					Author:    from,
+					Channel:   list,
+					Timestamp: date,
+				})
+			}
+		}
+
+		return nil
+	})
+}
+
+// readMbox calls f with the raw bytes of each message in an mbox. A
+// message starts at a "From " line at the beginning of the file or
+// after a blank line; ">From " escapes inside messages are undone.
+func readMbox(r io.Reader, f func([]byte) error) error {
+	br := bufio.NewReader(r)
+
+	var msg bytes.Buffer
+	var started, blank bool
+
+	flush := func() error {
+		if !started {
+			return nil
+		}
+
+		err := f(msg.Bytes())
+		msg.Reset()
+		return err
+	}
+
+	for {
+		line, err := br.ReadBytes('\n')
+		if len(line) > 0 {
+			if bytes.HasPrefix(line, []byte("From ")) && (!started || blank) {
+				if err := flush(); err != nil {
+					return err
+				}
+				started = true
+			} else if started {
+				if line[0] == '>' && bytes.HasPrefix(bytes.TrimLeft(line, ">"), []byte("From ")) {
+					line = line[1:]
+				}
+				msg.Write(line)
+			}
+
+			blank = len(bytes.TrimRight(line, "\r\n")) == 0
+		}
+
+		if err == io.EOF {
+			return flush()
+		} else if err != nil {
+			return err
+		}
+	}
+}
+
+// A header is the part of mail.Header and textproto.MIMEHeader that
+// mailText needs.
+type header interface {
+	Get(key string) string
+}
+
+// mailText returns the first text/plain part of a message body,
+// decoded to UTF-8.
+func mailText(h header, body io.Reader) (string, error) {
+	mediaType, params, err := mime.ParseMediaType(h.Get("Content-Type"))
+	if err != nil {
+		// RFC 2045 says a missing or broken Content-Type means
+		// US-ASCII plain text.
+		mediaType, params = "text/plain", nil
+	}
+
+	if strings.HasPrefix(mediaType, "multipart/") {
+		mr := multipart.NewReader(body, params["boundary"])
+		for {
+			part, err := mr.NextPart()
+			if err == io.EOF {
+				return "", nil
+			} else if err != nil {
+				return "", err
+			}
+
+			// Skip attachments, even text ones.
+			if disp, _, _ := mime.ParseMediaType(part.Header.Get("Content-Disposition")); disp == "attachment" {
+				continue
+			}
+
+			text, err := mailText(part.Header, part)
+			if err != nil || text != "" {
+				return text, err
+			}
+		}
+	}
+
+	if mediaType != "text/plain" {
+		return "", nil
+	}
+
+	switch strings.ToLower(h.Get("Content-Transfer-Encoding")) {
+	case "quoted-printable":
+		body = quotedprintable.NewReader(body)
+	case "base64":
+		body = base64.NewDecoder(base64.StdEncoding, &base64Cleaner{body})
+	}
+
+	if charset := params["charset"]; charset != "" {
+		if enc, err := htmlindex.Get(charset); err == nil {
+			body = enc.NewDecoder().Reader(body)
+		}
+	}
+
+	data, err := ioutil.ReadAll(body)
+	return string(data), err
+}
+
+// base64Cleaner drops the line breaks that wrap base64 bodies.
+type base64Cleaner struct {
+	r io.Reader
+}
+
+func (c *base64Cleaner) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+
+	j := 0
+	for _, b := range p[:n] {
+		if b != '\r' && b != '\n' {
+			p[j] = b
+			j++
+		}
+	}
+
+	return j, err
+}
+
+// Attribution lines like "On Mon, 3 Mar 2014, Alice wrote:", which
+// mail clients often wrap onto two lines.
+var (
+	attributionRegexp      = regexp.MustCompile(`^On .*wrote:$`)
+	attributionStartRegexp = regexp.MustCompile(`^On .*\d`)
+)
+
+// mailParagraphs strips quoted lines, attributions and the signature
+// from a message body and joins the rest into paragraphs.
+func mailParagraphs(body string) []string {
+	lines := strings.Split(strings.Replace(body, "\r\n", "\n", -1), "\n")
+
+	var paragraphs []string
+	var cur []string
+
+	flush := func() {
+		if len(cur) > 0 {
+			paragraphs = append(paragraphs, strings.Join(cur, " "))
+			cur = nil
+		}
+	}
+
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		if line == "-- " {
+			break
+		}
+
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case strings.HasPrefix(trimmed, ">"):
+			flush()
+		case attributionRegexp.MatchString(trimmed):
+			flush()
+		case attributionStartRegexp.MatchString(trimmed) && i+1 < len(lines) &&
+			strings.HasSuffix(strings.TrimSpace(lines[i+1]), "wrote:"):
+			flush()
+			i++
+		case trimmed == "":
+			flush()
+		default:
+			cur = append(cur, trimmed)
+		}
+	}
+
+	flush()
+	return paragraphs
+}
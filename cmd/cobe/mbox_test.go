@@ -0,0 +1,85 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+const testMbox = `From alice@example.com Mon Mar  3 12:00:00 2014
+From: Alice <alice@example.com>
+Subject: hello
+Content-Type: text/plain; charset=utf-8
+
+Hi all. Mr. Smith says hello
+to everyone.
+
+-- 
+Alice
+alice@example.com
+
+From bob@example.com Mon Mar  3 13:00:00 2014
+From: Bob <bob@example.com>
+Subject: Re: hello
+Content-Type: text/plain; charset=iso-8859-1
+Content-Transfer-Encoding: quoted-printable
+
+On Mon, Mar 3, 2014 at 12:00 PM, Alice <alice@example.com>
+wrote:
+> Hi all.
+> Mr. Smith says hello
+
+Caf=E9 later? It's a long=
+ line.
+>From here on, nothing.
+From the start it was odd.
+
+From carol@example.com Mon Mar  3 14:00:00 2014
+From: carol@example.com
+Subject: multipart
+MIME-Version: 1.0
+Content-Type: multipart/alternative; boundary="XYZ"
+
+--XYZ
+Content-Type: text/plain; charset=utf-8
+Content-Transfer-Encoding: base64
+
+T24gTW9uLCBCb2Igd3JvdGU6Cj4gcXVvdGVkCgpCYXNlIHNpeHR5IGZvdXIh
+Cg==
+--XYZ
+Content-Type: text/html
+
+<p>Ignored</p>
+--XYZ--
+`
+
+func TestLearnMbox(t *testing.T) {
+	dir := writeFiles(t, map[string]string{"test.mbox": testMbox})
+	defer os.RemoveAll(dir)
+
+	var tests = []struct {
+		opts     learnOptions
+		expected []string
+	}{
+		{learnOptions{}, []string{
+			"Hi all.",
+			"Mr. Smith says hello to everyone.",
+			"Café later?",
+			"It's a long line.",
+			"From here on, nothing.",
+			"From the start it was odd.",
+			"Base sixty four!",
+		}},
+		{learnOptions{Users: []string{"carol@example.com"}}, []string{
+			"Base sixty four!",
+		}},
+	}
+
+	for ti, tt := range tests {
+		got := learnAll(t, learnMbox, filepath.Join(dir, "test.mbox"), tt.opts)
+		if !reflect.DeepEqual(got, tt.expected) {
+			t.Errorf("[%d] expected %q, got %q", ti, tt.expected, got)
+		}
+	}
+}
@@ -0,0 +1,366 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	cobe "github.com/pteichman/go.cobe"
+)
+
+// A learnSession learns files into a brain in batches. Each batch is
+// committed with a checkpoint of how far into its file it got, so an
+// interrupted learn can resume without learning anything twice.
+type learnSession struct {
+	brain *cobe.Cobe2Brain
+	opts  *learnOptions
+
+	// batch is the number of lines per commit.
+	batch int
+
+	// resume continues each file from its checkpoint.
+	resume bool
+
+	progress *learnProgress
+}
+
+func (s *learnSession) learn(rec cobe.LearnRecord) {
+	s.brain.LearnRecord(rec)
+	s.progress.learned()
+}
+
+// learnLines learns a file in a lineFormat, committing every batch
+// lines.
+func (s *learnSession) learnLines(path string, parse lineFormat) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	name, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+
+	cp := newCheckpoint()
+	if s.resume {
+		saved, err := s.brain.Checkpoint(name)
+		if err != nil {
+			return err
+		}
+
+		if saved != "" {
+			cp, err = resumeCheckpoint(f, saved)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	s.progress.startFile(path, info.Size(), cp.offset)
+	defer s.progress.endFile()
+
+	err = s.brain.BeginBatch()
+	if err != nil {
+		return err
+	}
+
+	var n int
+	err = readLines(f, path, cp, parse, s.opts, s.learn, func(line string) error {
+		s.progress.read(len(line))
+
+		n++
+		if n%s.batch != 0 {
+			return nil
+		}
+
+		err := s.commit(name, cp)
+		if err != nil {
+			return err
+		}
+
+		return s.brain.BeginBatch()
+	})
+
+	if err != nil {
+		s.brain.RollbackBatch()
+		return err
+	}
+
+	return s.commit(name, cp)
+}
+
+// learnWhole learns a file or directory in one batch. Formats that
+// aren't line based can't resume part way through, but a file that
+// was learned completely is skipped when resuming.
+func (s *learnSession) learnWhole(path string, read learnFormat) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+
+	var name string
+	cp := newCheckpoint()
+
+	if !info.IsDir() {
+		name, err = filepath.Abs(path)
+		if err != nil {
+			return err
+		}
+
+		err = cp.addFile(path)
+		if err != nil {
+			return err
+		}
+
+		if s.resume {
+			saved, err := s.brain.Checkpoint(name)
+			if err != nil {
+				return err
+			}
+
+			if saved == cp.String() {
+				log.Printf("%s was already learned, skipping", path)
+				return nil
+			}
+		}
+	}
+
+	s.progress.startFile(path, info.Size(), 0)
+	defer s.progress.endFile()
+
+	err = s.brain.BeginBatch()
+	if err != nil {
+		return err
+	}
+
+	err = read(path, s.opts, s.learn)
+	if err != nil {
+		s.brain.RollbackBatch()
+		return err
+	}
+
+	if name == "" {
+		return s.brain.CommitBatch()
+	}
+
+	s.progress.read(int(info.Size()))
+	return s.commit(name, cp)
+}
+
+func (s *learnSession) commit(name string, cp *checkpoint) error {
+	err := s.brain.SetCheckpoint(name, cp.String())
+	if err != nil {
+		s.brain.RollbackBatch()
+		return err
+	}
+
+	return s.brain.CommitBatch()
+}
+
+// readLines parses each line of r with parse. Bad lines are logged
+// with their line numbers and skipped. cp is advanced past each line
+// before next is called with it.
+func readLines(r io.Reader, path string, cp *checkpoint, parse lineFormat,
+	opts *learnOptions, learn func(cobe.LearnRecord), next func(string) error) error {
+
+	br := bufio.NewReader(r)
+	for {
+		line, err := br.ReadString('\n')
+		if len(line) > 0 {
+			cp.add(line)
+
+			perr := parse(strings.TrimRight(line, "\r\n"), opts, learn)
+			if perr != nil {
+				log.Printf("%s:%d: %s", path, cp.lines, perr)
+			}
+
+			if nerr := next(line); nerr != nil {
+				return nerr
+			}
+		}
+
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+	}
+}
+
+// A checkpoint records how much of a file has been learned: its
+// offset, the number of lines before it, and a hash of the bytes
+// before it so a resume can tell if the file has changed.
+type checkpoint struct {
+	offset int64
+	lines  int64
+	hash   hash.Hash
+}
+
+func newCheckpoint() *checkpoint {
+	return &checkpoint{hash: sha1.New()}
+}
+
+func (cp *checkpoint) add(data string) {
+	cp.Write([]byte(data))
+}
+
+// Write advances the checkpoint past p.
+func (cp *checkpoint) Write(p []byte) (int, error) {
+	cp.offset += int64(len(p))
+	cp.lines += int64(bytes.Count(p, []byte("\n")))
+	return cp.hash.Write(p)
+}
+
+func (cp *checkpoint) addFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = io.Copy(cp, f)
+	return err
+}
+
+// String formats a checkpoint as it's saved in the brain: offset,
+// lines and hash separated by spaces.
+func (cp *checkpoint) String() string {
+	return fmt.Sprintf("%d %d %x", cp.offset, cp.lines, cp.hash.Sum(nil))
+}
+
+// resumeCheckpoint reads f up to the offset of a saved checkpoint,
+// checking that its contents haven't changed, and returns the
+// checkpoint to continue from. f is left at the checkpoint's offset.
+func resumeCheckpoint(f io.Reader, saved string) (*checkpoint, error) {
+	var offset, lines int64
+	var sum string
+
+	_, err := fmt.Sscanf(saved, "%d %d %s", &offset, &lines, &sum)
+	if err != nil {
+		return nil, fmt.Errorf("bad checkpoint %q: %s", saved, err)
+	}
+
+	cp := newCheckpoint()
+
+	_, err = io.Copy(cp, io.LimitReader(f, offset))
+	if err != nil {
+		return nil, err
+	}
+
+	if cp.offset != offset || cp.lines != lines || hex.EncodeToString(cp.hash.Sum(nil)) != sum {
+		return nil, errors.New("file has changed since its checkpoint")
+	}
+
+	return cp, nil
+}
+
+// learnProgress reports the lines, bytes, learn rate and estimated
+// time remaining of a learn session.
+type learnProgress struct {
+	out io.Writer
+
+	// every is the minimum time between reports.
+	every time.Duration
+
+	start time.Time
+	last  time.Time
+
+	path  string
+	size  int64
+	done  int64
+	bytes int64
+	lines int64
+}
+
+func newLearnProgress(out io.Writer) *learnProgress {
+	now := time.Now()
+	return &learnProgress{out: out, every: time.Second, start: now, last: now}
+}
+
+func (p *learnProgress) startFile(path string, size, offset int64) {
+	p.path, p.size, p.done = path, size, offset
+}
+
+func (p *learnProgress) endFile() {
+	p.report()
+	fmt.Fprintln(p.out)
+}
+
+func (p *learnProgress) learned() {
+	p.lines++
+
+	if time.Since(p.last) >= p.every {
+		p.report()
+	}
+}
+
+func (p *learnProgress) read(n int) {
+	p.done += int64(n)
+	p.bytes += int64(n)
+}
+
+func (p *learnProgress) report() {
+	p.last = time.Now()
+	elapsed := p.last.Sub(p.start).Seconds()
+
+	status := fmt.Sprintf("%s: %d lines, %s of %s", p.path, p.lines,
+		formatBytes(p.done), formatBytes(p.size))
+
+	if elapsed > 0 {
+		status += fmt.Sprintf(", %.0f lines/s", float64(p.lines)/elapsed)
+
+		rate := float64(p.bytes) / elapsed
+		if rate > 0 && p.done < p.size {
+			eta := time.Duration(float64(p.size-p.done)/rate) * time.Second
+			status += fmt.Sprintf(", ETA %s", eta)
+		}
+	}
+
+	// Pad to overwrite a longer previous status.
+	fmt.Fprintf(p.out, "\r%-79s", status)
+}
+
+// progressReader reports the bytes read from r to a learnProgress,
+// for learners that read a file without going through a learnSession.
+type progressReader struct {
+	r io.Reader
+	p *learnProgress
+}
+
+func (r *progressReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.p.read(n)
+	return n, err
+}
+
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	cobe "github.com/pteichman/go.cobe"
+)
+
+func TestLearnResume(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"corpus.txt": "the quick brown fox\nthe lazy dog sleeps\n" +
+			"a cat sat on the mat\n",
+	})
+	defer os.RemoveAll(dir)
+
+	corpus := filepath.Join(dir, "corpus.txt")
+
+	b, err := cobe.OpenCobe2Brain(filepath.Join(dir, "test.brain"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Close()
+
+	session := func(resume bool) *learnSession {
+		return &learnSession{
+			brain:    b,
+			opts:     &learnOptions{},
+			batch:    2,
+			resume:   resume,
+			progress: newLearnProgress(ioutil.Discard),
+		}
+	}
+
+	s := session(false)
+	err = s.learnFile(corpus, "lines")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if s.progress.lines != 3 {
+		t.Errorf("Expected 3 lines learned, got %d", s.progress.lines)
+	}
+
+	name, _ := filepath.Abs(corpus)
+	saved, err := b.Checkpoint(name)
+	if err != nil || !strings.HasPrefix(saved, "61 3 ") {
+		t.Errorf("Expected checkpoint at 61 bytes, 3 lines, got %q (%v)", saved, err)
+	}
+
+	// Appending to the corpus leaves the checkpoint valid, and a
+	// resume learns only the new lines.
+	f, err := os.OpenFile(corpus, os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString("one more line to learn\nand another one here\n")
+	f.Close()
+
+	s = session(true)
+	err = s.learnFile(corpus, "lines")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if s.progress.lines != 2 {
+		t.Errorf("Expected 2 lines learned on resume, got %d", s.progress.lines)
+	}
+
+	// Changing what was already learned breaks the checkpoint.
+	err = ioutil.WriteFile(corpus, []byte("something else entirely\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = session(true).learnFile(corpus, "lines")
+	if err == nil {
+		t.Error("Expected an error resuming a changed file")
+	}
+}
+
+func TestLearnResumeWhole(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"doc.md": "# Title\n\nSome prose to learn here. And another sentence.\n",
+	})
+	defer os.RemoveAll(dir)
+
+	b, err := cobe.OpenCobe2Brain(filepath.Join(dir, "test.brain"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Close()
+
+	for i, expected := range []int64{3, 0} {
+		s := &learnSession{
+			brain:    b,
+			opts:     &learnOptions{},
+			batch:    1000,
+			resume:   true,
+			progress: newLearnProgress(ioutil.Discard),
+		}
+
+		err = s.learnFile(filepath.Join(dir, "doc.md"), "markdown")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if s.progress.lines != expected {
+			t.Errorf("[%d] expected %d lines learned, got %d", i, expected, s.progress.lines)
+		}
+	}
+}
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	cobe "github.com/pteichman/go.cobe"
+)
+
+type slackMessage struct {
+	Type    string `json:"type"`
+	Subtype string `json:"subtype"`
+	User    string `json:"user"`
+	BotID   string `json:"bot_id"`
+	Text    string `json:"text"`
+	Ts      string `json:"ts"`
+}
+
+type slackUser struct {
+	ID    string `json:"id"`
+	Name  string `json:"name"`
+	IsBot bool   `json:"is_bot"`
+}
+
+// learnSlack learns the messages in a Slack export. The path is
+// either the export directory, with users.json and a directory of
+// daily JSON files per channel, or one of those daily files.
+func learnSlack(path string, opts *learnOptions, learn func(cobe.LearnRecord)) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+
+	if !info.IsDir() {
+		dir := filepath.Dir(path)
+		users, err := readSlackUsers(filepath.Join(dir, "..", "users.json"))
+		if err != nil {
+			return err
+		}
+
+		return learnSlackFile(path, filepath.Base(dir), users, opts, learn)
+	}
+
+	users, err := readSlackUsers(filepath.Join(path, "users.json"))
+	if err != nil {
+		return err
+	}
+
+	channels, err := ioutil.ReadDir(path)
+	if err != nil {
+		return err
+	}
+
+	for _, channel := range channels {
+		if !channel.IsDir() {
+			continue
+		}
+
+		files, err := filepath.Glob(filepath.Join(path, channel.Name(), "*.json"))
+		if err != nil {
+			return err
+		}
+		sort.Strings(files)
+
+		for _, file := range files {
+			err = learnSlackFile(file, channel.Name(), users, opts, learn)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// readSlackUsers maps user ids to users. The export may not include
+// users.json, in which case mentions keep their ids.
+func readSlackUsers(path string) (map[string]slackUser, error) {
+	users := make(map[string]slackUser)
+
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return users, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var list []slackUser
+	err = json.Unmarshal(data, &list)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, u := range list {
+		users[u.ID] = u
+	}
+
+	return users, nil
+}
+
+func learnSlackFile(path, channel string, users map[string]slackUser, opts *learnOptions, learn func(cobe.LearnRecord)) error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	var msgs []slackMessage
+	err = json.Unmarshal(data, &msgs)
+	if err != nil {
+		return err
+	}
+
+	for _, msg := range msgs {
+		// Subtypes mark joins, topic changes, bot messages and so
+		// on. Thread broadcasts are ordinary user messages.
+		if msg.Type != "message" || msg.BotID != "" ||
+			(msg.Subtype != "" && msg.Subtype != "thread_broadcast") {
+			continue
+		}
+
+		user, ok := users[msg.User]
+		if ok && user.IsBot {
+			continue
+		}
+
+		name := msg.User
+		if ok {
+			name = user.Name
+		}
+
+		if !opts.allow(channel, name) {
+			continue
+		}
+
+		learnChatText(decodeSlack(msg.Text, users), name, channel, slackTime(msg.Ts), learn)
+	}
+
+	return nil
+}
+
+// slackTime converts a message ts, seconds since the epoch with a
+// sequence number as the fraction, to a time.
+func slackTime(ts string) time.Time {
+	secs, err := strconv.ParseInt(strings.SplitN(ts, ".", 2)[0], 10, 64)
+	if err != nil {
+		return time.Time{}
+	}
+
+	return time.Unix(secs, 0).UTC()
+}
+
+var slackMarkupRegexp = regexp.MustCompile(`<([^<>]*)>`)
+
+var slackEntities = strings.NewReplacer("&lt;", "<", "&gt;", ">", "&amp;", "&")
+
+// decodeSlack turns Slack's <...> markup back into plain text: user
+// and channel mentions become @name and #channel, and links become
+// their label.
+func decodeSlack(text string, users map[string]slackUser) string {
+	text = slackMarkupRegexp.ReplaceAllStringFunc(text, func(m string) string {
+		target := m[1 : len(m)-1]
+
+		var label string
+		if i := strings.Index(target, "|"); i >= 0 {
+			target, label = target[:i], target[i+1:]
+		}
+
+		switch {
+		case strings.HasPrefix(target, "@"):
+			if label != "" {
+				return "@" + label
+			}
+			if u, ok := users[target[1:]]; ok {
+				return "@" + u.Name
+			}
+			return target
+		case strings.HasPrefix(target, "#"):
+			if label != "" {
+				return "#" + label
+			}
+			return target
+		case strings.HasPrefix(target, "!"):
+			// <!here>, <!channel> and <!subteam^ID|@team>
+			if label != "" {
+				return label
+			}
+			return "@" + strings.SplitN(target[1:], "^", 2)[0]
+		case label != "":
+			return label
+		}
+
+		return target
+	})
+
+	return slackEntities.Replace(text)
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"bufio"
+	"io"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// learnSubtitles learns the dialogue in an SRT or WebVTT file. Cue
+// numbers, timings, styling and sound descriptions are dropped, and
+// sentences that run across several cues are joined back together so
+// each utterance is learned as a single line.
+func learnSubtitles(r io.Reader, learn func(string)) error {
+	var pending string
+
+	flush := func() {
+		if pending != "" {
+			learn(pending)
+			pending = ""
+		}
+	}
+
+	err := readCues(r, func(lines []string) {
+		for _, line := range lines {
+			line = cleanCueLine(line)
+
+			// A leading dash marks a change of speaker.
+			if strings.HasPrefix(line, "-") && !strings.HasPrefix(line, "--") {
+				flush()
+				line = strings.TrimSpace(line[1:])
+			}
+
+			if line == "" {
+				continue
+			}
+
+			if pending != "" && endsUtterance(pending, line) {
+				flush()
+			}
+
+			if pending == "" {
+				pending = line
+			} else {
+				pending = joinCueText(pending, line)
+			}
+		}
+	})
+
+	flush()
+	return err
+}
+
+// readCues calls f with the text lines of each cue. Blocks without a
+// timing line are WebVTT headers, notes and styles, and are skipped.
+func readCues(r io.Reader, f func([]string)) error {
+	s := bufio.NewScanner(bufio.NewReader(r))
+
+	var block []string
+	flush := func() {
+		for i, line := range block {
+			if strings.Contains(line, "-->") {
+				f(block[i+1:])
+				break
+			}
+		}
+
+		block = nil
+	}
+
+	first := true
+	for s.Scan() {
+		line := s.Text()
+		if first {
+			line = strings.TrimPrefix(line, "\ufeff")
+			first = false
+		}
+
+		line = strings.TrimRight(line, " \t\r")
+		if line == "" {
+			flush()
+			continue
+		}
+
+		block = append(block, line)
+	}
+
+	flush()
+	return s.Err()
+}
+
+var (
+	// HTML-style tags, including WebVTT <v Name>, <c.class> and
+	// <00:00:01.000> timestamps.
+	cueTagRegexp = regexp.MustCompile(`</?[^<>]*>`)
+
+	// SSA override codes like {\an8}.
+	cueOverrideRegexp = regexp.MustCompile(`\{\\[^}]*\}`)
+
+	// Sound descriptions like [door slams] or ♪ lyrics.
+	cueSoundRegexp = regexp.MustCompile(`\[[^\]]*\]|♪[^♪]*(♪|$)`)
+)
+
+var cueEntities = strings.NewReplacer("&amp;", "&", "&lt;", "<", "&gt;", ">", "&nbsp;", " ", "&lrm;", "", "&rlm;", "")
+
+func cleanCueLine(line string) string {
+	line = cueTagRegexp.ReplaceAllString(line, "")
+	line = cueOverrideRegexp.ReplaceAllString(line, "")
+	line = cueSoundRegexp.ReplaceAllString(line, "")
+	line = cueEntities.Replace(line)
+
+	return strings.Join(strings.Fields(line), " ")
+}
+
+// endsUtterance reports whether text is a complete sentence that
+// next doesn't continue.
+func endsUtterance(text, next string) bool {
+	trimmed := strings.TrimRight(text, "\"')]”’»")
+	last, _ := utf8.DecodeLastRuneInString(trimmed)
+
+	if strings.HasSuffix(trimmed, "...") || last == '…' {
+		// A trailing ellipsis continues if the next cue picks up
+		// the sentence with another ellipsis or in lowercase.
+		first, _ := utf8.DecodeRuneInString(next)
+		return !strings.HasPrefix(next, "...") && first != '…' && !unicode.IsLower(first)
+	}
+
+	return last == '.' || last == '!' || last == '?'
+}
+
+// joinCueText joins a sentence that continues into the next cue,
+// dropping the ellipses that often mark the break.
+func joinCueText(text, next string) string {
+	if strings.HasPrefix(next, "...") || strings.HasPrefix(next, "…") {
+		text = strings.TrimRight(strings.TrimSuffix(text, "..."), "…")
+		next = strings.TrimLeft(strings.TrimPrefix(next, "..."), "…")
+	}
+
+	return strings.TrimSpace(text) + " " + strings.TrimSpace(next)
+}
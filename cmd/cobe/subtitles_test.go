@@ -0,0 +1,50 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLearnSubtitles(t *testing.T) {
+	var tests = []struct {
+		text     string
+		expected []string
+	}{
+		{"\ufeff1\r\n00:00:01,000 --> 00:00:03,000\r\n<i>Where are you</i>\r\ngoing tonight?\r\n\r\n" +
+			"2\r\n00:00:03,500 --> 00:00:05,000\r\n- Out.\r\n- With whom?\r\n\r\n" +
+			"3\r\n00:00:06,000 --> 00:00:08,000\r\n[door slams]\r\n\r\n" +
+			"4\r\n00:00:09,000 --> 00:00:10,000\r\n{\\an8}I told you that I would\r\n\r\n" +
+			"5\r\n00:00:10,000 --> 00:00:12,000\r\nnever go back...\r\n\r\n" +
+			"6\r\n00:00:12,000 --> 00:00:13,000\r\n...to that place.\r\n",
+			[]string{
+				"Where are you going tonight?",
+				"Out.",
+				"With whom?",
+				"I told you that I would never go back to that place.",
+			}},
+
+		{"WEBVTT - test\n\nNOTE this is\na comment\n\nSTYLE\n::cue { color: red }\n\n" +
+			"intro\n00:01.000 --> 00:03.000 align:start position:10%\n<v Roger>Hello &amp; welcome.</v>\n\n" +
+			"00:03.000 --> 00:05.000\n<c.yellow>Thanks</c> <00:00:04.000>for having me.\n\n" +
+			"00:05.000 --> 00:06.000\n♪ la la la ♪\n",
+			[]string{
+				"Hello & welcome.",
+				"Thanks for having me.",
+			}},
+	}
+
+	for ti, tt := range tests {
+		var lines []string
+		err := learnSubtitles(strings.NewReader(tt.text), func(s string) {
+			lines = append(lines, s)
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !reflect.DeepEqual(lines, tt.expected) {
+			t.Errorf("[%d] expected %q, got %q", ti, tt.expected, lines)
+		}
+	}
+}
@@ -79,18 +79,60 @@ func RunForever(b *cobe.Cobe2Brain) {
 	}
 }
 
+// lastReply is the reply /why and /trace explain.
+var lastReply *cobe.Reply
+
 func RunOne(b *cobe.Cobe2Brain) error {
 	line, err := linenoise.Line("> ")
 	if err != nil {
 		return err
 	}
 
+	if line == "/why" {
+		explain(b, lastReply)
+		return nil
+	}
+
+	if line == "/trace" {
+		if lastReply != nil {
+			fmt.Println(lastReply.Trace())
+		}
+		return nil
+	}
+
 	if line != "" {
 		linenoise.AddHistory(line)
 		b.Learn(line)
 	}
 
-	fmt.Println(b.Reply(line))
+	lastReply = b.BestReply(line, cobe.DefaultReplyOptions)
+	if lastReply == nil {
+		fmt.Println("I don't know enough to answer you yet!")
+	} else {
+		fmt.Println(lastReply)
+	}
 
 	return nil
 }
+
+// explain prints the learned lines each word of a reply came from.
+func explain(b *cobe.Cobe2Brain, r *cobe.Reply) {
+	sources, err := b.Explain(r)
+	if err != nil {
+		fmt.Printf("Can't explain: %s\n", err)
+		return
+	}
+
+	for _, src := range sources {
+		fmt.Printf("%s: %d lines\n", src.Word, src.Total)
+
+		for _, line := range src.Lines {
+			where := line.Author
+			if line.Channel != "" {
+				where += " in " + line.Channel
+			}
+
+			fmt.Printf("    %s: %s\n", where, line.Text)
+		}
+	}
+}
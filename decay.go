@@ -0,0 +1,26 @@
+package cobe
+
+import "time"
+
+// SetDecay makes the brain favor what it learned recently: each edge
+// keeps a weight that halves every halfLife, and replies are scored
+// and searched by weight instead of count. Lines learned from a
+// LearnRecord with a Timestamp are weighted as of that time. Edge
+// weights start from their counts when decay is first enabled.
+func (b *Cobe2Brain) SetDecay(halfLife time.Duration) error {
+	return b.graph.setDecay(halfLife)
+}
+
+// DelDecay goes back to scoring replies by edge counts.
+func (b *Cobe2Brain) DelDecay() error {
+	return b.graph.delDecay()
+}
+
+// Decay is periodic maintenance for brains with decay enabled. Weights
+// are stored as of the time each edge was last seen and decayed when
+// read; Decay brings every stored weight up to now, so the database
+// reflects current weights, with a small floor so edges unseen for a
+// very long time keep a nonzero weight.
+func (b *Cobe2Brain) Decay() error {
+	return b.graph.decay(time.Now().Unix())
+}
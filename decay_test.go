@@ -0,0 +1,197 @@
+package cobe
+
+import (
+	"math"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestAddWeight(t *testing.T) {
+	day := float64(24 * 60 * 60)
+
+	var tests = []struct {
+		weight   float64
+		lastSeen int64
+		t        int64
+		expected float64
+		seen     int64
+	}{
+		{0, 0, 100, 1, 100},
+		{1, 100, 100, 2, 100},
+		{4, 0, 86400, 3, 86400},
+		{4, 0, 2 * 86400, 2, 2 * 86400},
+		// An older observation counts for less.
+		{1, 86400, 0, 1.5, 86400},
+	}
+
+	for ti, tt := range tests {
+		weight, seen := addWeight(tt.weight, tt.lastSeen, tt.t, day)
+		if math.Abs(weight-tt.expected) > 1e-9 || seen != tt.seen {
+			t.Errorf("[%d] expected %f at %d, got %f at %d", ti,
+				tt.expected, tt.seen, weight, seen)
+		}
+	}
+}
+
+// edgeTo returns the edge along path that adds word.
+func edgeTo(t *testing.T, b *Cobe2Brain, path []nodeID, word string) (nodeID, nodeID) {
+	order := b.graph.order
+	for i := 1; i < len(path); i++ {
+		if b.graph.getNodeTokens(path[i])[order-1] == word {
+			return path[i-1], path[i]
+		}
+	}
+
+	t.Fatalf("No edge to %s", word)
+	return 0, 0
+}
+
+func TestDecay(t *testing.T) {
+	b, filename := newTestBrain(t)
+	defer os.Remove(filename)
+
+	err := b.SetDecay(30 * 24 * time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	old := time.Date(2014, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 5; i++ {
+		b.LearnRecord(LearnRecord{Text: "the joke is about llamas", Timestamp: old})
+	}
+	b.LearnRecord(LearnRecord{Text: "the joke is about alpacas"})
+
+	logprob := func(word string) float64 {
+		prev, next := edgeTo(t, b, learnedPath(b, "the joke is about "+word), word)
+		return b.graph.getEdgeLogprob(prev, next)
+	}
+
+	llamas, alpacas := logprob("llamas"), logprob("alpacas")
+	if alpacas < -1e-6 || llamas > alpacas-10 {
+		t.Errorf("Expected recent alpacas to dominate: %f, %f", llamas, alpacas)
+	}
+
+	// Traces show the decayed weights behind each logprob.
+	trace := newReply(b.graph, learnedPath(b, "the joke is about llamas"), 1).Trace()
+	if !trace.Decayed {
+		t.Error("Expected a decayed trace")
+	}
+
+	for i, e := range trace.Edges {
+		if e.Weight <= 0 || e.NodeWeight <= 0 {
+			t.Errorf("[%d] expected decayed weights, got %g, %g", i, e.Weight, e.NodeWeight)
+		} else if math.Abs(e.Logprob-math.Log2(e.Weight/e.NodeWeight)) > 1e-6 {
+			t.Errorf("[%d] logprob %f doesn't match weights %g, %g", i, e.Logprob,
+				e.Weight, e.NodeWeight)
+		}
+	}
+
+	err = b.Decay()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if l, a := logprob("llamas"), logprob("alpacas"); math.Abs(l-llamas) > 1e-6 || math.Abs(a-alpacas) > 1e-6 {
+		t.Errorf("Decay changed logprobs: %f, %f to %f, %f", llamas, alpacas, l, a)
+	}
+
+	err = b.DelDecay()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if l := logprob("llamas"); math.Abs(l-math.Log2(5.0/6)) > 1e-9 {
+		t.Errorf("Expected counts after DelDecay, got %f", l)
+	}
+}
+
+// Enabling decay starts weights from counts, so replies score the
+// same until something new is learned.
+func TestSetDecay(t *testing.T) {
+	filename, err := tmpCopy("data/pg11.brain")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(filename)
+
+	b, err := OpenCobe2Brain(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reply := b.BestReply("Alice", DefaultReplyOptions)
+	if reply == nil {
+		t.Fatal("got a nil reply")
+	}
+
+	var counts []float64
+	for i := 0; i < len(reply.nodes)-1; i++ {
+		counts = append(counts, b.graph.getEdgeLogprob(reply.nodes[i], reply.nodes[i+1]))
+	}
+
+	err = b.SetDecay(time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < len(reply.nodes)-1; i++ {
+		weights := b.graph.getEdgeLogprob(reply.nodes[i], reply.nodes[i+1])
+		if math.Abs(weights-counts[i]) > 1e-9 {
+			t.Errorf("[%d] expected logprob %f, got %f", i, counts[i], weights)
+		}
+	}
+}
+
+func TestForgetAuthorDecay(t *testing.T) {
+	ts := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	learn := func(b *Cobe2Brain, recs []LearnRecord) {
+		for _, rec := range recs {
+			rec.Timestamp = ts
+			b.LearnRecord(rec)
+		}
+	}
+
+	alice := []LearnRecord{{Text: "the cat sat on the mat", Author: "alice"}}
+	others := []LearnRecord{
+		{Text: "the cat sat on the rug", Author: "bob"},
+		{Text: "the dog sat on the rug", Author: "bob"},
+	}
+
+	b, filename := newTestBrain(t)
+	defer os.Remove(filename)
+
+	expected, expectedFilename := newTestBrain(t)
+	defer os.Remove(expectedFilename)
+
+	for _, brain := range []*Cobe2Brain{b, expected} {
+		if err := brain.SetDecay(time.Hour); err != nil {
+			t.Fatal(err)
+		}
+		if err := brain.SetProvenance(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	learn(b, alice)
+	learn(b, others)
+	learn(expected, others)
+
+	if _, err := b.ForgetAuthor("alice"); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, rec := range others {
+		path := learnedPath(b, rec.Text)
+		ePath := learnedPath(expected, rec.Text)
+
+		for i := 0; i < len(path)-1; i++ {
+			lp := b.graph.getEdgeLogprob(path[i], path[i+1])
+			elp := expected.graph.getEdgeLogprob(ePath[i], ePath[i+1])
+			if math.Abs(lp-elp) > 1e-9 {
+				t.Errorf("%s [%d]: expected logprob %f, got %f", rec.Text, i, elp, lp)
+			}
+		}
+	}
+}
@@ -0,0 +1,184 @@
+package cobe
+
+import (
+	"strings"
+)
+
+// An emoticonClass is a group of emoticons and emoji that express the
+// same thing. The cobe stemmer gives them all the same stem, so any
+// one of them can be answered with a reply using another.
+type emoticonClass struct {
+	stem      string
+	emoticons []string
+	emoji     []emoji
+}
+
+// An emoji is listed with its CLDR short name, to keep the table
+// below readable.
+type emoji struct {
+	text string
+	name string
+}
+
+// emoticonClasses is the table of equivalent expressions. The stems
+// for smileys and frownies match Python cobe; brains stemmed before a
+// class was added need set-stemmer run again to pick it up.
+var emoticonClasses = []emoticonClass{
+	{":)",
+		[]string{":)", ":-)", "=)", ":]", "(:"},
+		[]emoji{
+			{"🙂", "slightly smiling face"},
+			{"😊", "smiling face with smiling eyes"},
+			{"☺", "smiling face"},
+			{"😀", "grinning face"},
+			{"😃", "grinning face with big eyes"},
+			{"😇", "smiling face with halo"},
+			{"😺", "grinning cat"},
+		}},
+	{":D",
+		[]string{":D", ":-D", "=D", "xD", "XD"},
+		[]emoji{
+			{"😂", "face with tears of joy"},
+			{"🤣", "rolling on the floor laughing"},
+			{"😆", "grinning squinting face"},
+			{"😄", "grinning face with smiling eyes"},
+			{"😁", "beaming face with smiling eyes"},
+			{"😅", "grinning face with sweat"},
+			{"😹", "cat with tears of joy"},
+		}},
+	{"<3",
+		[]string{"<3", "♥"},
+		[]emoji{
+			{"❤", "red heart"},
+			{"😍", "smiling face with heart-eyes"},
+			{"🥰", "smiling face with hearts"},
+			{"😘", "face blowing a kiss"},
+			{"💕", "two hearts"},
+			{"💖", "sparkling heart"},
+			{"💗", "growing heart"},
+			{"💘", "heart with arrow"},
+			{"😻", "smiling cat with heart-eyes"},
+		}},
+	{";)",
+		[]string{";)", ";-)", ";]"},
+		[]emoji{
+			{"😉", "winking face"},
+			{"😜", "winking face with tongue"},
+		}},
+	{":P",
+		[]string{":P", ":-P", ":p", ":-p", "xP"},
+		[]emoji{
+			{"😛", "face with tongue"},
+			{"😝", "squinting face with tongue"},
+			{"😋", "face savoring food"},
+		}},
+	{":(",
+		[]string{":(", ":-(", ":'(", "</3"},
+		[]emoji{
+			{"☹", "frowning face"},
+			{"🙁", "slightly frowning face"},
+			{"😦", "frowning face with open mouth"},
+			{"😢", "crying face"},
+			{"😭", "loudly crying face"},
+			{"😞", "disappointed face"},
+			{"😔", "pensive face"},
+			{"💔", "broken heart"},
+		}},
+	{">:(",
+		[]string{">:(", ">:-(", "D:<"},
+		[]emoji{
+			{"😠", "angry face"},
+			{"😡", "enraged face"},
+			{"🤬", "face with symbols on mouth"},
+			{"👿", "angry face with horns"},
+			{"💢", "anger symbol"},
+		}},
+	{":O",
+		[]string{":O", ":-O", ":o", ":-o", "o_O", "O_o"},
+		[]emoji{
+			{"😮", "face with open mouth"},
+			{"😯", "hushed face"},
+			{"😲", "astonished face"},
+			{"😱", "face screaming in fear"},
+			{"😳", "flushed face"},
+			{"🤯", "exploding head"},
+			{"🙀", "weary cat"},
+		}},
+	{"+1",
+		[]string{"+1"},
+		[]emoji{
+			{"👍", "thumbs up"},
+			{"👌", "OK hand"},
+			{"✅", "check mark button"},
+			{"✔", "check mark"},
+			{"🙌", "raising hands"},
+		}},
+	{"-1",
+		[]string{"-1"},
+		[]emoji{
+			{"👎", "thumbs down"},
+			{"❌", "cross mark"},
+		}},
+}
+
+var (
+	// emoticonStems maps whole tokens to their class stem.
+	emoticonStems map[string]string
+
+	// emojiStems maps single emoji runes to their class stem.
+	emojiStems map[rune]string
+)
+
+func init() {
+	emoticonStems = make(map[string]string)
+	emojiStems = make(map[rune]string)
+
+	for _, c := range emoticonClasses {
+		for _, e := range c.emoticons {
+			emoticonStems[e] = c.stem
+		}
+
+		for _, e := range c.emoji {
+			emoticonStems[e.text] = c.stem
+
+			r := []rune(e.text)
+			if len(r) == 1 {
+				emojiStems[r[0]] = c.stem
+			}
+		}
+	}
+}
+
+// emoticonStem returns the stem for a token that is exactly a known
+// emoticon or emoji.
+func emoticonStem(token string) (string, bool) {
+	stem, ok := emoticonStems[stripEmojiModifiers(token)]
+	return stem, ok
+}
+
+// emojiStem returns the stem of the first known emoji in token, or ""
+// if there isn't one.
+func emojiStem(token string) string {
+	for _, r := range token {
+		if stem, ok := emojiStems[r]; ok {
+			return stem
+		}
+	}
+
+	return ""
+}
+
+// stripEmojiModifiers removes variation selectors and skin tone
+// modifiers, so "❤️" and "👍🏽" look up as "❤" and "👍".
+func stripEmojiModifiers(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r == '\uFE0E' || r == '\uFE0F':
+			return -1
+		case r >= 0x1F3FB && r <= 0x1F3FF:
+			return -1
+		}
+
+		return r
+	}, s)
+}
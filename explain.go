@@ -0,0 +1,65 @@
+package cobe
+
+import (
+	"errors"
+	"time"
+)
+
+// A LearnedLine is a line the brain learned, as recorded when
+// provenance is enabled.
+type LearnedLine struct {
+	Author    string
+	Channel   string
+	Timestamp time.Time
+	Text      string
+}
+
+// An EdgeSource lists the learned lines that taught a reply one of
+// its words.
+type EdgeSource struct {
+	// Word is the token the edge adds to the reply.
+	Word string
+
+	// Lines holds the most recent of the lines that taught the
+	// edge, and Total counts all of them.
+	Lines []LearnedLine
+	Total int
+
+	// Authors counts all the lines that taught the edge by author.
+	Authors map[string]int
+}
+
+// explainLines limits the lines listed for each edge of a reply.
+const explainLines = 5
+
+// Explain returns the sources of each word of a reply: the lines that
+// taught the edge leading to it. Only lines learned with an author
+// since provenance was enabled are known; see SetProvenance.
+func (b *Cobe2Brain) Explain(r *Reply) ([]EdgeSource, error) {
+	if r == nil {
+		return nil, errors.New("no reply to explain")
+	}
+
+	var ret []EdgeSource
+	for i, word := range r.words() {
+		lines, err := b.graph.getEdgeLines(r.nodes[i], r.nodes[i+1],
+			explainLines)
+		if err != nil {
+			return nil, err
+		}
+
+		authors, err := b.graph.getEdgeAuthors(r.nodes[i], r.nodes[i+1])
+		if err != nil {
+			return nil, err
+		}
+
+		var total int
+		for _, n := range authors {
+			total += n
+		}
+
+		ret = append(ret, EdgeSource{word, lines, total, authors})
+	}
+
+	return ret, nil
+}
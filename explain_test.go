@@ -0,0 +1,109 @@
+package cobe
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestExplain(t *testing.T) {
+	b, filename := newTestBrain(t)
+	defer os.Remove(filename)
+
+	opts := ReplyOptions{Duration: 50 * time.Millisecond}
+
+	b.Learn("the hen sat on the wall")
+	if _, err := b.Explain(b.BestReply("hen", opts)); err == nil {
+		t.Error("Expected an error without provenance")
+	}
+
+	err := b.SetProvenance()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b.LearnRecord(LearnRecord{Text: "the cat sat on the mat", Author: "alice", Channel: "#cobe"})
+	b.LearnRecord(LearnRecord{Text: "the dog sat on the mat", Author: "bob"})
+
+	reply := b.BestReply("cat", opts)
+	if reply == nil {
+		t.Fatal("got a nil reply")
+	}
+
+	sources, err := b.Explain(reply)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var words []string
+	for _, src := range sources {
+		words = append(words, src.Word)
+
+		if len(src.Lines) != src.Total {
+			t.Errorf("%s: expected %d lines, got %d", src.Word, src.Total, len(src.Lines))
+		}
+
+		if src.Word == "cat" {
+			if src.Total != 1 || src.Lines[0].Author != "alice" ||
+				src.Lines[0].Channel != "#cobe" ||
+				src.Lines[0].Text != "the cat sat on the mat" {
+				t.Errorf("Unexpected sources for cat: %v", src)
+			}
+		}
+	}
+
+	if strings.Join(words, " ") != reply.String() {
+		t.Errorf("Expected the words of %q, got %q", reply, words)
+	}
+}
+
+// Explain counts every line by author, not just those it lists, and
+// sees lines learned in an open batch.
+func TestExplainAuthors(t *testing.T) {
+	b, filename := newTestBrain(t)
+	defer os.Remove(filename)
+
+	err := b.SetProvenance()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = b.BeginBatch()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.CommitBatch()
+
+	for i := 0; i < explainLines+2; i++ {
+		b.LearnRecord(LearnRecord{Text: "the cat sat on the mat", Author: "carol"})
+	}
+	b.LearnRecord(LearnRecord{Text: "the cat sat on the mat", Author: "alice"})
+
+	path := learnedPath(b, "the cat sat on the mat")
+	reply := newReply(b.graph, path, 0)
+
+	sources, err := b.Explain(reply)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, src := range sources {
+		if src.Word != "cat" {
+			continue
+		}
+
+		if src.Total != explainLines+3 || len(src.Lines) != explainLines {
+			t.Errorf("Expected %d of %d lines, got %d of %d", explainLines,
+				explainLines+3, len(src.Lines), src.Total)
+		}
+
+		if src.Authors["carol"] != explainLines+2 || src.Authors["alice"] != 1 {
+			t.Errorf("Unexpected authors: %v", src.Authors)
+		}
+
+		return
+	}
+
+	t.Errorf("No source for cat in %v", sources)
+}
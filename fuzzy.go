@@ -0,0 +1,127 @@
+package cobe
+
+import (
+	"unicode/utf8"
+)
+
+// bkTree is a Burkhard-Keller tree over token text. It's used to find
+// known words within a small edit distance of a misspelled one.
+type bkTree struct {
+	root *bkNode
+}
+
+type bkNode struct {
+	text     string
+	tokenIds []tokenID
+	children map[int]*bkNode
+}
+
+func (t *bkTree) add(text string, id tokenID) {
+	if t.root == nil {
+		t.root = &bkNode{text: text, tokenIds: []tokenID{id}}
+		return
+	}
+
+	n := t.root
+	for {
+		d := levenshtein(text, n.text)
+		if d == 0 {
+			n.tokenIds = append(n.tokenIds, id)
+			return
+		}
+
+		child, ok := n.children[d]
+		if !ok {
+			if n.children == nil {
+				n.children = make(map[int]*bkNode)
+			}
+
+			n.children[d] = &bkNode{text: text, tokenIds: []tokenID{id}}
+			return
+		}
+
+		n = child
+	}
+}
+
+// nearest returns the tokens closest to text, as long as they're
+// within maxDist edits of it.
+func (t *bkTree) nearest(text string, maxDist int) []tokenID {
+	if t.root == nil {
+		return nil
+	}
+
+	var ret []tokenID
+	best := maxDist + 1
+
+	left := []*bkNode{t.root}
+	for len(left) > 0 {
+		n := left[len(left)-1]
+		left = left[:len(left)-1]
+
+		d := levenshtein(text, n.text)
+		if d < best {
+			best = d
+			ret = append([]tokenID(nil), n.tokenIds...)
+		} else if d == best && d <= maxDist {
+			ret = append(ret, n.tokenIds...)
+		}
+
+		// By the triangle inequality, only children at
+		// distance d-best..d+best from n can be close enough.
+		for cd, child := range n.children {
+			if cd >= d-best && cd <= d+best {
+				left = append(left, child)
+			}
+		}
+	}
+
+	return ret
+}
+
+// levenshtein returns the number of single rune insertions, deletions
+// and substitutions needed to turn a into b.
+func levenshtein(a, b string) int {
+	ra := []rune(a)
+	rb := []rune(b)
+
+	prev := make([]int, len(rb)+1)
+	cur := make([]int, len(rb)+1)
+
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(ra); i++ {
+		cur[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+
+			cur[j] = min3(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+
+		prev, cur = cur, prev
+	}
+
+	return prev[len(rb)]
+}
+
+func min3(a, b, c int) int {
+	if b < a {
+		a = b
+	}
+	if c < a {
+		a = c
+	}
+
+	return a
+}
+
+// fuzzyCandidate reports whether word is long enough to be matched
+// within maxDist edits. Short words are too close to everything.
+func fuzzyCandidate(word string, maxDist int) bool {
+	return utf8.RuneCountInString(word) > 2*maxDist
+}
@@ -0,0 +1,106 @@
+package cobe
+
+import (
+	"os"
+	"testing"
+)
+
+func TestLevenshtein(t *testing.T) {
+	var tests = []struct {
+		a, b     string
+		expected int
+	}{
+		{"", "", 0},
+		{"", "abc", 3},
+		{"abc", "", 3},
+		{"platypus", "platypus", 0},
+		{"platpyus", "platypus", 2},
+		{"kitten", "sitting", 3},
+		{"naïve", "naive", 1},
+	}
+
+	for ti, tt := range tests {
+		d := levenshtein(tt.a, tt.b)
+		if d != tt.expected {
+			t.Errorf("[%d] %s/%s: expected %d, was %d", ti, tt.a, tt.b,
+				tt.expected, d)
+		}
+	}
+}
+
+func TestBKTree(t *testing.T) {
+	tree := &bkTree{}
+	for i, word := range []string{"platypus", "lynx", "ocelot", "baboon", "sloth", "slot"} {
+		tree.add(word, tokenID(i+1))
+	}
+
+	var tests = []struct {
+		word     string
+		maxDist  int
+		expected []tokenID
+	}{
+		{"platpyus", 2, []tokenID{1}},
+		{"platpyus", 1, nil},
+		{"ocelots", 1, []tokenID{3}},
+		{"slath", 2, []tokenID{5}},
+		{"zebra", 2, nil},
+	}
+
+	for ti, tt := range tests {
+		ids := tree.nearest(tt.word, tt.maxDist)
+		if !nodeEqual(ids, tt.expected) {
+			t.Errorf("[%d] %s: expected %v, was %v", ti, tt.word,
+				tt.expected, ids)
+		}
+	}
+}
+
+func TestFuzzyPivots(t *testing.T) {
+	filename, err := tmpCopy("data/pg11.brain")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer os.Remove(filename)
+
+	g, err := openGraph(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer g.close()
+
+	if ids := g.getTokensByFuzzy("Alcie"); len(ids) != 0 {
+		t.Errorf("Expected no fuzzy tokens before setFuzzy, got %v", ids)
+	}
+
+	err = g.setFuzzy(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ids := g.getTokensByFuzzy("Alcie")
+	if !containsId(ids, 18) {
+		t.Errorf("Expected Alcie to match 18, was %v", ids)
+	}
+
+	// Too short to match anything at distance 2.
+	if ids := g.getTokensByFuzzy("Al"); len(ids) != 0 {
+		t.Errorf("Expected no fuzzy tokens for Al, got %v", ids)
+	}
+
+	token := g.getOrCreateToken("platypus")
+	ids = g.getTokensByFuzzy("platpyus")
+	if len(ids) != 1 || ids[0] != token {
+		t.Errorf("Expected platpyus to match [%d], was %v", token, ids)
+	}
+}
+
+func containsId(ids []tokenID, id tokenID) bool {
+	for _, i := range ids {
+		if i == id {
+			return true
+		}
+	}
+
+	return false
+}
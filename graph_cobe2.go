@@ -3,12 +3,14 @@ package cobe
 import (
 	"container/list"
 	"database/sql"
+	"errors"
 	"fmt"
+	"hash/fnv"
 	"log"
 	"math"
 	"math/rand"
 	"os"
-	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
@@ -16,7 +18,6 @@ import (
 )
 
 import (
-	"bitbucket.org/tebeka/snowball"
 	_ "github.com/mattn/go-sqlite3"
 )
 
@@ -39,7 +40,52 @@ type graph struct {
 
 	q *stmts
 
-	stemmer stemmer
+	// tx is the transaction of the current batch, if any. While
+	// it's open, q holds statements bound to it and dbq holds the
+	// original ones.
+	tx  *sql.Tx
+	dbq *stmts
+
+	stemmer Stemmer
+
+	// languages detects the language of each learned line when
+	// per-language stemming is enabled. langStemmers holds a
+	// stemmer for each of its languages.
+	languages    *langDetector
+	langStemmers map[string]Stemmer
+
+	// caseFold is true if token_folds is maintained for this graph.
+	caseFold bool
+
+	// tokenClasses is true if the tokens table stores each token's
+	// class. Older brains don't until migrated, and classify tokens
+	// from their text instead.
+	tokenClasses bool
+
+	// fuzzy indexes word tokens by edit distance. It's nil
+	// unless fuzzy matching has been enabled.
+	fuzzy     *bkTree
+	fuzzyDist int
+
+	// synonyms is true if the brain has a synonyms table.
+	synonyms bool
+
+	// dedupe is the number of recently learned lines remembered
+	// in learned_hashes to suppress duplicates, or 0.
+	dedupe int
+
+	// novelty is the number of consecutive nodes fingerprinted in
+	// learned_spans, or 0 if learned sentences aren't recorded.
+	// See noveltyNodes.
+	novelty int
+
+	// halfLife is the time in seconds for edge weights to decay
+	// by half, or 0 if the brain doesn't track recency.
+	halfLife float64
+
+	// provenance is true if the brain has a learned_lines table
+	// recording who taught each edge.
+	provenance bool
 
 	order        int
 	endTokenID   tokenID
@@ -52,8 +98,14 @@ type stmts struct {
 	updateInfo *sql.Stmt
 	deleteInfo *sql.Stmt
 
-	selectToken *sql.Stmt
-	insertToken *sql.Stmt
+	selectToken       *sql.Stmt
+	selectTokenNocase *sql.Stmt
+	selectTokenText   *sql.Stmt
+	insertToken       *sql.Stmt
+
+	selectTokenClass *sql.Stmt
+	selectNodeClass  *sql.Stmt
+	selectNodeTokens *sql.Stmt
 
 	selectNode *sql.Stmt
 	insertNode *sql.Stmt
@@ -71,6 +123,34 @@ type stmts struct {
 
 	insertStem       *sql.Stmt
 	selectStemTokens *sql.Stmt
+
+	insertFold       *sql.Stmt
+	selectFoldTokens *sql.Stmt
+
+	selectSynonyms *sql.Stmt
+
+	insertLangStem       *sql.Stmt
+	selectLangStemTokens *sql.Stmt
+
+	selectLineHash *sql.Stmt
+	insertLineHash *sql.Stmt
+	trimLineHashes *sql.Stmt
+
+	selectSpan *sql.Stmt
+	insertSpan *sql.Stmt
+
+	insertLine        *sql.Stmt
+	insertLineEdge    *sql.Stmt
+	selectEdgeLines   *sql.Stmt
+	selectEdgeAuthors *sql.Stmt
+
+	selectEdgeWeight  *sql.Stmt
+	updateEdgeWeight  *sql.Stmt
+	selectNodeWeight  *sql.Stmt
+	updateNodeWeight  *sql.Stmt
+	selectEdgeWeights *sql.Stmt
+	fwdAdjWeights     *sql.Stmt
+	revAdjWeights     *sql.Stmt
 }
 
 func openGraph(path string) (*graph, error) {
@@ -111,13 +191,110 @@ func openGraph(path string) (*graph, error) {
 		return nil, err
 	}
 
-	lang, err := g.getInfoString("stemmer")
-	if lang != "" {
-		s, err := snowball.New(lang)
+	g.tokenClasses, err = hasColumn(db, "tokens", "class")
+	if err == nil {
+		err = prepareClassSql(db, stmts, g.order, g.tokenClasses)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	name, err := g.getInfoString("stemmer")
+	if name != "" {
+		g.stemmer, err = newStemmer(name)
+		if err != nil {
+			db.Close()
+			return nil, fmt.Errorf("initializing stemmer: %s", err)
+		}
+	}
+
+	langs, err := g.getInfoString("languages")
+	if langs != "" {
+		err = g.initLanguages(strings.Split(langs, ","))
+		if err != nil {
+			db.Close()
+			return nil, fmt.Errorf("initializing languages: %s", err)
+		}
+	}
+
+	fold, err := g.getInfoString("casefold")
+	if fold == "1" {
+		err = prepareFoldSql(db, stmts)
+		if err != nil {
+			log.Printf("Error initializing case folding: %s", err)
+		} else {
+			g.caseFold = true
+		}
+	}
+
+	g.synonyms, err = hasTable(db, "synonyms")
+	if err == nil && g.synonyms {
+		err = prepareSynonymSql(db, stmts)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	halfLife, err := g.getInfoString("half-life")
+	if halfLife != "" {
+		d, err := time.ParseDuration(halfLife)
+		if err == nil {
+			err = prepareDecaySql(db, stmts)
+		}
+
+		if err != nil {
+			log.Printf("Error initializing decay: %s", err)
+		} else {
+			g.halfLife = d.Seconds()
+		}
+	}
+
+	g.provenance, err = hasTable(db, "learned_lines")
+	if err == nil && g.provenance {
+		err = migrateLearnedLines(db)
+	}
+	if err == nil && g.provenance {
+		err = prepareProvenanceSql(db, stmts)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	dist, err := g.getInfoString("fuzzy")
+	if dist != "" {
+		n, err := strconv.Atoi(dist)
+		if err != nil {
+			log.Printf("Error initializing fuzzy matching: %s", err)
+		} else {
+			g.buildFuzzy(n)
+		}
+	}
+
+	dedupe, err := g.getInfoString("dedupe")
+	if dedupe != "" {
+		n, err := strconv.Atoi(dedupe)
+		if err == nil {
+			err = prepareDedupeSql(db, stmts)
+		}
+
+		if err != nil {
+			log.Printf("Error initializing dedupe: %s", err)
+		} else {
+			g.dedupe = n
+		}
+	}
+
+	novelty, err := g.getInfoString("novelty")
+	if novelty != "" {
+		n, err := strconv.Atoi(novelty)
+		if err == nil {
+			err = prepareNoveltySql(db, stmts)
+		}
+
 		if err != nil {
-			log.Printf("Error initializing stemmer: %s", err)
+			log.Printf("Error initializing novelty: %s", err)
 		} else {
-			g.stemmer = newCobeStemmer(s)
+			g.novelty = noveltyNodes(n, g.order)
 		}
 	}
 
@@ -128,6 +305,10 @@ func openGraph(path string) (*graph, error) {
 }
 
 func (g *graph) close() {
+	if g.tx != nil {
+		g.rollback()
+	}
+
 	if g.db != nil {
 		g.db.Close()
 		g.db = nil
@@ -236,8 +417,29 @@ func prepareSql(db *sql.DB, stmts *stmts, order int) error {
 		return err
 	}
 
-	stmts.insertToken, err = db.Prepare(
-		"INSERT INTO tokens (text, is_word) VALUES (?, ?)")
+	stmts.selectTokenNocase, err = db.Prepare(
+		"SELECT id FROM tokens WHERE text = ? COLLATE NOCASE")
+	if err != nil {
+		return err
+	}
+
+	stmts.selectTokenText, err = db.Prepare(
+		"SELECT text FROM tokens WHERE id = ?")
+	if err != nil {
+		return err
+	}
+
+	texts := nStrings(order, func(i int) string {
+		return fmt.Sprintf("t%d.text", i)
+	})
+	joins := nStrings(order, func(i int) string {
+		return fmt.Sprintf("JOIN tokens t%d ON t%d.id = nodes.token%d_id", i, i, i)
+	})
+
+	query := fmt.Sprintf("SELECT %s FROM nodes %s WHERE nodes.id = ?",
+		strings.Join(texts, ", "), strings.Join(joins, " "))
+
+	stmts.selectNodeTokens, err = db.Prepare(query)
 	if err != nil {
 		return err
 	}
@@ -246,7 +448,7 @@ func prepareSql(db *sql.DB, stmts *stmts, order int) error {
 		return fmt.Sprintf("token%d_id = ?", i)
 	})
 
-	query := fmt.Sprintf("SELECT id FROM nodes WHERE %s",
+	query = fmt.Sprintf("SELECT id FROM nodes WHERE %s",
 		strings.Join(args, " AND "))
 
 	stmts.selectNode, err = db.Prepare(query)
@@ -347,6 +549,255 @@ func prepareSql(db *sql.DB, stmts *stmts, order int) error {
 	return nil
 }
 
+// prepareLangSql prepares the statements for per-language stems,
+// which need the token_stems.lang column.
+func prepareLangSql(db *sql.DB, stmts *stmts) error {
+	var err error
+
+	stmts.insertLangStem, err = db.Prepare(
+		"INSERT OR IGNORE INTO token_stems (token_id, stem, lang) " +
+			"VALUES (?, ?, ?)")
+	if err != nil {
+		return err
+	}
+
+	stmts.selectLangStemTokens, err = db.Prepare("SELECT token_id " +
+		"FROM token_stems WHERE token_stems.stem = ? AND token_stems.lang = ?")
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// prepareClassSql prepares the statements that create tokens and look
+// up their classes. Brains without a class column select token text
+// in place of the class, to be classified as it's read.
+func prepareClassSql(db *sql.DB, stmts *stmts, order int, classes bool) error {
+	var err error
+
+	insert := "INSERT INTO tokens (text, is_word) VALUES (?, ?)"
+	column := "text"
+	if classes {
+		insert = "INSERT INTO tokens (text, is_word, class) VALUES (?, ?, ?)"
+		column = "class"
+	}
+
+	stmts.insertToken, err = db.Prepare(insert)
+	if err != nil {
+		return err
+	}
+
+	stmts.selectTokenClass, err = db.Prepare(fmt.Sprintf(
+		"SELECT %s FROM tokens WHERE id = ?", column))
+	if err != nil {
+		return err
+	}
+
+	query := fmt.Sprintf("SELECT tokens.%s FROM nodes, tokens "+
+		"WHERE nodes.id = ? AND nodes.token%d_id = tokens.id", column, order-1)
+
+	stmts.selectNodeClass, err = db.Prepare(query)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// prepareFoldSql prepares the token_folds statements. These are kept
+// apart from prepareSql because the table only exists in brains that
+// have had case folding enabled.
+func prepareFoldSql(db *sql.DB, stmts *stmts) error {
+	var err error
+
+	stmts.insertFold, err = db.Prepare(
+		"INSERT INTO token_folds (token_id, fold) VALUES (?, ?)")
+	if err != nil {
+		return err
+	}
+
+	stmts.selectFoldTokens, err = db.Prepare("SELECT token_id " +
+		"FROM token_folds WHERE token_folds.fold = ?")
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func prepareSynonymSql(db *sql.DB, stmts *stmts) error {
+	var err error
+
+	stmts.selectSynonyms, err = db.Prepare("SELECT DISTINCT b.word " +
+		"FROM synonyms a, synonyms b " +
+		"WHERE a.word = ? AND a.class = b.class")
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// closeStmts closes the statements that are prepared and clears them,
+// before the tables they refer to are dropped or they're prepared
+// again.
+func closeStmts(stmts ...**sql.Stmt) {
+	for _, s := range stmts {
+		if *s != nil {
+			(*s).Close()
+			*s = nil
+		}
+	}
+}
+
+func prepareDedupeSql(db *sql.DB, stmts *stmts) error {
+	var err error
+
+	stmts.selectLineHash, err = db.Prepare(
+		"SELECT count(*) FROM learned_hashes WHERE hash = ?")
+	if err != nil {
+		return err
+	}
+
+	stmts.insertLineHash, err = db.Prepare("INSERT OR REPLACE " +
+		"INTO learned_hashes (hash, seq) VALUES (?, " +
+		"(SELECT coalesce(max(seq), 0) + 1 FROM learned_hashes))")
+	if err != nil {
+		return err
+	}
+
+	stmts.trimLineHashes, err = db.Prepare("DELETE FROM learned_hashes " +
+		"WHERE seq <= (SELECT max(seq) FROM learned_hashes) - ?")
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func prepareNoveltySql(db *sql.DB, stmts *stmts) error {
+	var err error
+
+	stmts.selectSpan, err = db.Prepare(
+		"SELECT count(*) FROM learned_spans WHERE hash = ?")
+	if err != nil {
+		return err
+	}
+
+	stmts.insertSpan, err = db.Prepare(
+		"INSERT OR IGNORE INTO learned_spans (hash) VALUES (?)")
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func prepareProvenanceSql(db *sql.DB, stmts *stmts) error {
+	var err error
+
+	stmts.insertLine, err = db.Prepare("INSERT INTO learned_lines " +
+		"(author, channel, time, text) VALUES (?, ?, ?, ?)")
+	if err != nil {
+		return err
+	}
+
+	stmts.insertLineEdge, err = db.Prepare("INSERT INTO line_edges " +
+		"(line_id, edge_id) SELECT ?, id FROM edges " +
+		"WHERE prev_node = ? AND next_node = ? AND has_space = ?")
+	if err != nil {
+		return err
+	}
+
+	stmts.selectEdgeLines, err = db.Prepare("SELECT learned_lines.author, " +
+		"learned_lines.channel, learned_lines.time, learned_lines.text " +
+		"FROM edges, line_edges, learned_lines " +
+		"WHERE edges.prev_node = ? AND edges.next_node = ? " +
+		"AND line_edges.edge_id = edges.id " +
+		"AND learned_lines.id = line_edges.line_id " +
+		"ORDER BY learned_lines.id DESC LIMIT ?")
+	if err != nil {
+		return err
+	}
+
+	stmts.selectEdgeAuthors, err = db.Prepare("SELECT learned_lines.author, " +
+		"count(*) FROM edges, line_edges, learned_lines " +
+		"WHERE edges.prev_node = ? AND edges.next_node = ? " +
+		"AND line_edges.edge_id = edges.id " +
+		"AND learned_lines.id = line_edges.line_id " +
+		"GROUP BY learned_lines.author")
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func prepareDecaySql(db *sql.DB, stmts *stmts) error {
+	var err error
+
+	stmts.selectEdgeWeight, err = db.Prepare("SELECT weight, last_seen " +
+		"FROM edges WHERE prev_node = ? AND next_node = ? AND has_space = ?")
+	if err != nil {
+		return err
+	}
+
+	stmts.updateEdgeWeight, err = db.Prepare("UPDATE edges " +
+		"SET weight = ?, last_seen = ? " +
+		"WHERE prev_node = ? AND next_node = ? AND has_space = ?")
+	if err != nil {
+		return err
+	}
+
+	stmts.selectNodeWeight, err = db.Prepare(
+		"SELECT weight, last_seen FROM nodes WHERE id = ?")
+	if err != nil {
+		return err
+	}
+
+	stmts.updateNodeWeight, err = db.Prepare(
+		"UPDATE nodes SET weight = ?, last_seen = ? WHERE id = ?")
+	if err != nil {
+		return err
+	}
+
+	stmts.selectEdgeWeights, err = db.Prepare(
+		"SELECT edges.weight, edges.last_seen, nodes.weight, nodes.last_seen " +
+			"FROM edges, nodes " +
+			"WHERE edges.prev_node = ? AND edges.next_node = ? " +
+			"AND edges.prev_node = nodes.id")
+	if err != nil {
+		return err
+	}
+
+	stmts.fwdAdjWeights, err = db.Prepare("SELECT next_node, weight, " +
+		"last_seen FROM edges WHERE prev_node = ?")
+	if err != nil {
+		return err
+	}
+
+	stmts.revAdjWeights, err = db.Prepare("SELECT prev_node, weight, " +
+		"last_seen FROM edges WHERE next_node = ?")
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func hasTable(db *sql.DB, name string) (bool, error) {
+	var count int
+
+	err := db.QueryRow("SELECT count(*) FROM sqlite_master "+
+		"WHERE type = 'table' AND name = ?", name).Scan(&count)
+	if err != nil {
+		return false, err
+	}
+
+	return count > 0, nil
+}
+
 func nStrings(n int, f func(int) string) []string {
 	var ret = make([]string, n)
 	for i := 0; i < n; i++ {
@@ -356,124 +807,1773 @@ func nStrings(n int, f func(int) string) []string {
 	return ret
 }
 
-func (g *graph) getInfoString(key string) (string, error) {
-	g.lock.RLock()
-	defer g.lock.RUnlock()
+// inTx returns a copy of q with every statement bound to tx.
+func (q *stmts) inTx(tx *sql.Tx) *stmts {
+	bind := func(s *sql.Stmt) *sql.Stmt {
+		if s == nil {
+			return nil
+		}
+		return tx.Stmt(s)
+	}
 
-	var value string
+	return &stmts{
+		selectInfo: bind(q.selectInfo),
+		insertInfo: bind(q.insertInfo),
+		updateInfo: bind(q.updateInfo),
+		deleteInfo: bind(q.deleteInfo),
 
-	err := g.q.selectInfo.QueryRow(key).Scan(&value)
-	if err != nil {
-		stats.Inc("error", 1, 1.0)
-		return "", err
-	}
+		selectToken:       bind(q.selectToken),
+		selectTokenNocase: bind(q.selectTokenNocase),
+		selectTokenText:   bind(q.selectTokenText),
+		insertToken:       bind(q.insertToken),
 
-	return value, nil
-}
+		selectTokenClass: bind(q.selectTokenClass),
+		selectNodeClass:  bind(q.selectNodeClass),
+		selectNodeTokens: bind(q.selectNodeTokens),
 
-func (g *graph) delInfoString(key string) error {
-	g.lock.Lock()
+		selectNode: bind(q.selectNode),
+		insertNode: bind(q.insertNode),
+
+		incrEdge:   bind(q.incrEdge),
+		insertEdge: bind(q.insertEdge),
+
+		fwdAdj: bind(q.fwdAdj),
+		revAdj: bind(q.revAdj),
+
+		selectNodeText:    bind(q.selectNodeText),
+		selectEdgeCounts:  bind(q.selectEdgeCounts),
+		selectRandomToken: bind(q.selectRandomToken),
+		selectRandomNode:  bind(q.selectRandomNode),
+
+		insertStem:       bind(q.insertStem),
+		selectStemTokens: bind(q.selectStemTokens),
+
+		insertFold:       bind(q.insertFold),
+		selectFoldTokens: bind(q.selectFoldTokens),
+
+		selectSynonyms: bind(q.selectSynonyms),
+
+		insertLangStem:       bind(q.insertLangStem),
+		selectLangStemTokens: bind(q.selectLangStemTokens),
+
+		selectLineHash: bind(q.selectLineHash),
+		insertLineHash: bind(q.insertLineHash),
+		trimLineHashes: bind(q.trimLineHashes),
+
+		selectSpan: bind(q.selectSpan),
+		insertSpan: bind(q.insertSpan),
+
+		insertLine:        bind(q.insertLine),
+		insertLineEdge:    bind(q.insertLineEdge),
+		selectEdgeLines:   bind(q.selectEdgeLines),
+		selectEdgeAuthors: bind(q.selectEdgeAuthors),
+
+		selectEdgeWeight:  bind(q.selectEdgeWeight),
+		updateEdgeWeight:  bind(q.updateEdgeWeight),
+		selectNodeWeight:  bind(q.selectNodeWeight),
+		updateNodeWeight:  bind(q.updateNodeWeight),
+		selectEdgeWeights: bind(q.selectEdgeWeights),
+		fwdAdjWeights:     bind(q.fwdAdjWeights),
+		revAdjWeights:     bind(q.revAdjWeights),
+	}
+}
+
+// begin starts a transaction that the graph's statements run in
+// until commit or rollback. Maintenance that queries g.db directly,
+// like set-stemmer, must not run during one.
+func (g *graph) begin() error {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	if g.tx != nil {
+		return errors.New("batch already started")
+	}
+
+	tx, err := g.db.Begin()
+	if err != nil {
+		stats.Inc("error", 1, 1.0)
+		return err
+	}
+
+	g.tx = tx
+	g.dbq = g.q
+	g.q = g.q.inTx(tx)
+
+	return nil
+}
+
+func (g *graph) commit() error {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	if g.tx == nil {
+		return errors.New("no batch started")
+	}
+
+	err := g.tx.Commit()
+	if err != nil {
+		stats.Inc("error", 1, 1.0)
+	}
+
+	g.endTx()
+	return err
+}
+
+func (g *graph) rollback() error {
+	g.lock.Lock()
+
+	if g.tx == nil {
+		g.lock.Unlock()
+		return errors.New("no batch started")
+	}
+
+	err := g.tx.Rollback()
+	g.endTx()
+
+	fuzzy, dist := g.fuzzy != nil, g.fuzzyDist
+	g.lock.Unlock()
+
+	// Tokens added to the fuzzy index during the batch are gone
+	// from the database now.
+	if fuzzy {
+		g.buildFuzzy(dist)
+	}
+
+	return err
+}
+
+func (g *graph) endTx() {
+	g.q = g.dbq
+	g.tx = nil
+	g.dbq = nil
+}
+
+func (g *graph) getInfoString(key string) (string, error) {
+	g.lock.RLock()
+	defer g.lock.RUnlock()
+
+	var value string
+
+	err := g.q.selectInfo.QueryRow(key).Scan(&value)
+	if err != nil {
+		stats.Inc("error", 1, 1.0)
+		return "", err
+	}
+
+	return value, nil
+}
+
+func (g *graph) delInfoString(key string) error {
+	g.lock.Lock()
 	defer g.lock.Unlock()
 
 	_, err := g.q.deleteInfo.Exec(key)
 	return err
 }
 
-func (g *graph) setInfoString(key, value string) error {
+func (g *graph) setInfoString(key, value string) error {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	res, err := g.q.updateInfo.Exec(value, key)
+	if err != nil {
+		stats.Inc("error", 1, 1.0)
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		stats.Inc("error", 1, 1.0)
+		return err
+	}
+
+	if rows > 0 {
+		return nil
+	}
+
+	res, err = g.q.insertInfo.Exec(key, value)
+	if err != nil {
+		stats.Inc("error", 1, 1.0)
+		return err
+	}
+
+	return nil
+}
+
+func (g *graph) delStemmer() error {
+	g.delInfoString("stemmer")
+	g.delInfoString("languages")
+
+	g.lock.Lock()
+	g.stemmer = nil
+	g.languages = nil
+	g.langStemmers = nil
+	g.lock.Unlock()
+
+	return g.deleteTokenStems()
+}
+
+func (g *graph) setStemmer(name string) error {
+	stemmer, err := newStemmer(name)
+	if err != nil {
+		return err
+	}
+
+	g.deleteTokenStems()
+	g.updateTokenStems(stemmer)
+	g.delInfoString("languages")
+	g.setInfoString("stemmer", name)
+
+	g.lock.Lock()
+	g.languages = nil
+	g.langStemmers = nil
+	g.stemmer = stemmer
+	g.lock.Unlock()
+
+	return nil
+}
+
+// setLanguages replaces the brain's stemmer with per-language
+// stemmers for langs. Each learned line is stemmed in its detected
+// language. Tokens learned before this are stemmed in every language,
+// since the lines they came from are long gone.
+func (g *graph) setLanguages(langs []string) error {
+	err := g.migrateLangStems()
+	if err != nil {
+		return err
+	}
+
+	err = g.initLanguages(langs)
+	if err != nil {
+		return err
+	}
+
+	g.deleteTokenStems()
+
+	err = g.createLangStemIndexes()
+	if err != nil {
+		return err
+	}
+
+	for _, lang := range langs {
+		g.updateLangStems(lang)
+	}
+
+	g.delInfoString("stemmer")
+	g.setInfoString("languages", strings.Join(langs, ","))
+
+	g.lock.Lock()
+	g.stemmer = nil
+	g.lock.Unlock()
+
+	return nil
+}
+
+func (g *graph) initLanguages(langs []string) error {
+	detector, err := newLangDetector(langs)
+	if err != nil {
+		return err
+	}
+
+	stemmers := make(map[string]Stemmer)
+	for _, lang := range langs {
+		stemmers[lang], err = newStemmer(lang)
+		if err != nil {
+			return err
+		}
+	}
+
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	err = prepareLangSql(g.db, g.q)
+	if err != nil {
+		return err
+	}
+
+	g.languages = detector
+	g.langStemmers = stemmers
+
+	return nil
+}
+
+// migrateLangStems adds the lang column to token_stems. Stems from a
+// single stemmer have an empty lang.
+func (g *graph) migrateLangStems() error {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	ok, err := hasColumn(g.db, "token_stems", "lang")
+	if err != nil || ok {
+		return err
+	}
+
+	log.Println("Migrating table: token_stems (adding lang)")
+	_, err = g.db.Exec(
+		"ALTER TABLE token_stems ADD COLUMN lang TEXT NOT NULL DEFAULT ''")
+	return err
+}
+
+func (g *graph) createLangStemIndexes() error {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	var err error
+
+	_, err = g.db.Exec("CREATE UNIQUE INDEX IF NOT EXISTS " +
+		"token_stems_lang_id ON token_stems (token_id, lang)")
+	if err != nil {
+		return err
+	}
+
+	_, err = g.db.Exec("CREATE INDEX IF NOT EXISTS " +
+		"token_stems_lang_stem ON token_stems (stem, lang)")
+	return err
+}
+
+func (g *graph) updateLangStems(lang string) error {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	s := g.langStemmers[lang]
+
+	rows, err := g.db.Query("SELECT id, text FROM tokens")
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id int64
+		var text string
+
+		rows.Scan(&id, &text)
+		stem := s.Stem(text)
+
+		if stem != "" {
+			g.q.insertLangStem.Exec(id, stem, lang)
+		}
+	}
+
+	return nil
+}
+
+// detectLanguage returns the language of text, or "" if per-language
+// stemming isn't enabled.
+func (g *graph) detectLanguage(text string) string {
+	g.lock.RLock()
+	languages := g.languages
+	g.lock.RUnlock()
+
+	if languages == nil {
+		return ""
+	}
+
+	return languages.detect(text)
+}
+
+// addLangStems records the stems of tokens in lang, for tokens that
+// don't have one in that language yet.
+func (g *graph) addLangStems(tokens []string, tokenIds []tokenID, lang string) {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	s := g.langStemmers[lang]
+	if s == nil {
+		return
+	}
+
+	for i, token := range tokens {
+		if tokenIds[i] == spaceTokenID {
+			continue
+		}
+
+		stem := s.Stem(token)
+		if stem != "" {
+			g.q.insertLangStem.Exec(tokenIds[i], stem, lang)
+		}
+	}
+}
+
+func (g *graph) deleteTokenStems() error {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	var err error
+
+	_, err = g.db.Exec("DROP INDEX IF EXISTS token_stems_stem")
+	if err != nil {
+		return err
+	}
+
+	_, err = g.db.Exec("DROP INDEX IF EXISTS token_stems_id")
+	if err != nil {
+		return err
+	}
+
+	_, err = g.db.Exec("DROP INDEX IF EXISTS token_stems_lang_id")
+	if err != nil {
+		return err
+	}
+
+	_, err = g.db.Exec("DROP INDEX IF EXISTS token_stems_lang_stem")
+	if err != nil {
+		return err
+	}
+
+	_, err = g.db.Exec("DELETE FROM token_stems")
+	return err
+}
+
+func (g *graph) updateTokenStems(s Stemmer) error {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	rows, err := g.db.Query("SELECT id, text FROM tokens")
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id int64
+		var text string
+
+		rows.Scan(&id, &text)
+		stem := s.Stem(text)
+
+		if stem != "" {
+			g.q.insertStem.Exec(id, stem)
+		}
+	}
+
+	return nil
+}
+
+func (g *graph) delCaseFold() error {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	g.caseFold = false
+
+	_, err := g.q.deleteInfo.Exec("casefold")
+	if err != nil {
+		return err
+	}
+
+	closeStmts(&g.q.insertFold, &g.q.selectFoldTokens)
+
+	_, err = g.db.Exec("DROP TABLE IF EXISTS token_folds")
+	return err
+}
+
+// setCaseFold creates the token_folds table and fills it with the
+// case-folded text of every known token. New tokens are added as they
+// are created.
+func (g *graph) setCaseFold() error {
+	err := g.createTokenFolds()
+	if err != nil {
+		return err
+	}
+
+	g.lock.Lock()
+	err = prepareFoldSql(g.db, g.q)
+	g.lock.Unlock()
+
+	if err != nil {
+		return err
+	}
+
+	err = g.updateTokenFolds()
+	if err != nil {
+		return err
+	}
+
+	err = g.setInfoString("casefold", "1")
+	if err != nil {
+		return err
+	}
+
+	g.lock.Lock()
+	g.caseFold = true
+	g.lock.Unlock()
+
+	return nil
+}
+
+func (g *graph) createTokenFolds() error {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	var err error
+
+	_, err = g.db.Exec(`
+CREATE TABLE IF NOT EXISTS token_folds (
+	token_id INTEGER,
+	fold TEXT NOT NULL)`)
+	if err != nil {
+		return err
+	}
+
+	_, err = g.db.Exec("DELETE FROM token_folds")
+	if err != nil {
+		return err
+	}
+
+	_, err = g.db.Exec("CREATE INDEX IF NOT EXISTS token_folds_fold " +
+		"ON token_folds (fold)")
+	return err
+}
+
+func (g *graph) updateTokenFolds() error {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	rows, err := g.db.Query("SELECT id, text FROM tokens")
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id int64
+		var text string
+
+		rows.Scan(&id, &text)
+		fold := foldCase(text)
+
+		if fold != "" {
+			g.q.insertFold.Exec(id, fold)
+		}
+	}
+
+	return nil
+}
+
+func (g *graph) delDedupe() error {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	g.dedupe = 0
+
+	_, err := g.q.deleteInfo.Exec("dedupe")
+	if err != nil {
+		return err
+	}
+
+	closeStmts(&g.q.selectLineHash, &g.q.insertLineHash, &g.q.trimLineHashes)
+
+	_, err = g.db.Exec("DROP TABLE IF EXISTS learned_hashes")
+	return err
+}
+
+// setDedupe creates the learned_hashes table, which remembers the
+// hashes of the last n lines learned.
+func (g *graph) setDedupe(n int) error {
+	if n < 1 {
+		return fmt.Errorf("invalid dedupe size: %d", n)
+	}
+
+	g.lock.Lock()
+	_, err := g.db.Exec(`
+CREATE TABLE IF NOT EXISTS learned_hashes (
+	hash INTEGER PRIMARY KEY,
+	seq INTEGER NOT NULL)`)
+	if err == nil {
+		_, err = g.db.Exec("CREATE INDEX IF NOT EXISTS learned_hashes_seq " +
+			"ON learned_hashes (seq)")
+	}
+	if err == nil {
+		closeStmts(&g.q.selectLineHash, &g.q.insertLineHash, &g.q.trimLineHashes)
+		err = prepareDedupeSql(g.db, g.q)
+	}
+	g.lock.Unlock()
+
+	if err != nil {
+		return err
+	}
+
+	err = g.setInfoString("dedupe", strconv.Itoa(n))
+	if err != nil {
+		return err
+	}
+
+	g.lock.Lock()
+	g.dedupe = n
+	_, err = g.q.trimLineHashes.Exec(n)
+	g.lock.Unlock()
+
+	return err
+}
+
+// seenLine reports whether text is among the recently learned lines,
+// and remembers it as the most recent one. It's always false when
+// dedupe is disabled.
+func (g *graph) seenLine(text string) bool {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	if g.dedupe == 0 {
+		return false
+	}
+
+	hash := lineHash(text)
+
+	var count int
+	err := g.q.selectLineHash.QueryRow(hash).Scan(&count)
+	if err != nil {
+		stats.Inc("error", 1, 1.0)
+		log.Printf("Selecting line hash: %s", err)
+	}
+
+	_, err = g.q.insertLineHash.Exec(hash)
+	if err != nil {
+		stats.Inc("error", 1, 1.0)
+		log.Printf("Inserting line hash: %s", err)
+	}
+
+	_, err = g.q.trimLineHashes.Exec(g.dedupe)
+	if err != nil {
+		stats.Inc("error", 1, 1.0)
+		log.Printf("Trimming line hashes: %s", err)
+	}
+
+	return count > 0
+}
+
+// lineHash is the hash dedupe remembers a learned line by.
+func lineHash(text string) int64 {
+	h := fnv.New64a()
+	h.Write([]byte(text))
+	return int64(h.Sum64())
+}
+
+func (g *graph) delNovelty() error {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	g.novelty = 0
+
+	_, err := g.q.deleteInfo.Exec("novelty")
+	if err != nil {
+		return err
+	}
+
+	closeStmts(&g.q.selectSpan, &g.q.insertSpan)
+
+	_, err = g.db.Exec("DROP TABLE IF EXISTS learned_spans")
+	return err
+}
+
+// noveltyNodes returns the number of consecutive nodes that span one
+// more token than the longest run a reply may share with a learned
+// sentence. Each node holds order tokens, overlapping its neighbors by
+// all but one.
+func noveltyNodes(tokens int, order int) int {
+	return tokens + 2 - order
+}
+
+// setNovelty creates the learned_spans table, which records every
+// learned sentence by the hashes of its node path and of each run of
+// nodes spanning more than n tokens.
+func (g *graph) setNovelty(n int) error {
+	if n < g.order {
+		return fmt.Errorf("invalid novelty span: %d (must be at least %d)",
+			n, g.order)
+	}
+
+	g.lock.Lock()
+	_, err := g.db.Exec(`
+CREATE TABLE IF NOT EXISTS learned_spans (
+	hash INTEGER PRIMARY KEY)`)
+	if err == nil {
+		closeStmts(&g.q.selectSpan, &g.q.insertSpan)
+		err = prepareNoveltySql(g.db, g.q)
+	}
+	g.lock.Unlock()
+
+	if err != nil {
+		return err
+	}
+
+	err = g.setInfoString("novelty", strconv.Itoa(n))
+	if err != nil {
+		return err
+	}
+
+	g.lock.Lock()
+	g.novelty = noveltyNodes(n, g.order)
+	g.lock.Unlock()
+
+	return nil
+}
+
+// spans returns the hashes that fingerprint a node path: the whole
+// path and each run of novelty nodes in it. Runs holding the end
+// tokens that pad a sentence aren't counted.
+func (g *graph) spans(path []nodeID) []int64 {
+	ret := []int64{int64(hash(path))}
+
+	if len(path) < 2*g.order {
+		return ret
+	}
+
+	inner := path[g.order : len(path)-g.order]
+	for i := 0; i+g.novelty <= len(inner); i++ {
+		ret = append(ret, int64(hash(inner[i:i+g.novelty])))
+	}
+
+	return ret
+}
+
+// addSpans records the fingerprints of a learned node path. It does
+// nothing unless novelty is enabled.
+func (g *graph) addSpans(path []nodeID) {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	if g.novelty == 0 {
+		return
+	}
+
+	for _, h := range g.spans(path) {
+		_, err := g.q.insertSpan.Exec(h)
+		if err != nil {
+			stats.Inc("error", 1, 1.0)
+			log.Printf("Inserting span: %s", err)
+		}
+	}
+}
+
+// isCopy reports whether a node path is a learned sentence or shares
+// a fingerprinted run of nodes with one. It's always false when
+// novelty is disabled.
+func (g *graph) isCopy(path []nodeID) bool {
+	g.lock.RLock()
+	defer g.lock.RUnlock()
+
+	if g.novelty == 0 {
+		return false
+	}
+
+	for _, h := range g.spans(path) {
+		var count int
+		err := g.q.selectSpan.QueryRow(h).Scan(&count)
+		if err != nil {
+			stats.Inc("error", 1, 1.0)
+			log.Printf("Selecting span: %s", err)
+		}
+
+		if count > 0 {
+			return true
+		}
+	}
+
+	return false
+}
+
+// setProvenance creates the learned_lines and line_edges tables,
+// which link each line learned from a known author to the edges it
+// incremented, in both directions.
+func (g *graph) setProvenance() error {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	_, err := g.db.Exec(`
+CREATE TABLE IF NOT EXISTS learned_lines (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	author TEXT NOT NULL,
+	channel TEXT NOT NULL,
+	time INTEGER NOT NULL,
+	text TEXT NOT NULL DEFAULT '')`)
+	if err != nil {
+		return err
+	}
+
+	_, err = g.db.Exec(`
+CREATE TABLE IF NOT EXISTS line_edges (
+	line_id INTEGER NOT NULL REFERENCES learned_lines(id),
+	edge_id INTEGER NOT NULL REFERENCES edges(id))`)
+	if err != nil {
+		return err
+	}
+
+	_, err = g.db.Exec("CREATE INDEX IF NOT EXISTS learned_lines_author " +
+		"ON learned_lines (author)")
+	if err != nil {
+		return err
+	}
+
+	_, err = g.db.Exec("CREATE INDEX IF NOT EXISTS line_edges_line " +
+		"ON line_edges (line_id)")
+	if err != nil {
+		return err
+	}
+
+	_, err = g.db.Exec("CREATE INDEX IF NOT EXISTS line_edges_edge " +
+		"ON line_edges (edge_id)")
+	if err != nil {
+		return err
+	}
+
+	closeStmts(&g.q.insertLine, &g.q.insertLineEdge, &g.q.selectEdgeLines,
+		&g.q.selectEdgeAuthors)
+
+	err = prepareProvenanceSql(g.db, g.q)
+	if err != nil {
+		return err
+	}
+
+	g.provenance = true
+
+	return nil
+}
+
+func (g *graph) delProvenance() error {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	g.provenance = false
+
+	closeStmts(&g.q.insertLine, &g.q.insertLineEdge, &g.q.selectEdgeLines,
+		&g.q.selectEdgeAuthors)
+
+	_, err := g.db.Exec("DROP TABLE IF EXISTS line_edges")
+	if err != nil {
+		return err
+	}
+
+	_, err = g.db.Exec("DROP TABLE IF EXISTS learned_lines")
+	return err
+}
+
+// addLine records that rec's author taught the edges along path with
+// text. It does nothing unless provenance is enabled and the author
+// is known.
+func (g *graph) addLine(rec *LearnRecord, text string, path []nodeID, edges []edge) {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	if !g.provenance || rec == nil || rec.Author == "" {
+		return
+	}
+
+	var ts int64
+	if !rec.Timestamp.IsZero() {
+		ts = rec.Timestamp.Unix()
+	}
+
+	res, err := g.q.insertLine.Exec(rec.Author, rec.Channel, ts, text)
+	if err != nil {
+		stats.Inc("error", 1, 1.0)
+		log.Printf("Inserting learned line: %s", err)
+		return
+	}
+
+	line, err := res.LastInsertId()
+	if err != nil {
+		stats.Inc("error", 1, 1.0)
+		log.Printf("Learned line id: %s", err)
+		return
+	}
+
+	for i, e := range edges {
+		_, err = g.q.insertLineEdge.Exec(line, path[i], path[i+1], e.hasSpace)
+		if err != nil {
+			stats.Inc("error", 1, 1.0)
+			log.Printf("Inserting line edge: %s", err)
+		}
+	}
+}
+
+// forgetAuthor removes the lines author taught, decrementing the
+// counts of their edges. Edges, nodes and tokens left unused are
+// deleted. It returns the number of lines forgotten.
+func (g *graph) forgetAuthor(author string) (int, error) {
+	g.lock.Lock()
+
+	if g.tx != nil {
+		g.lock.Unlock()
+		return 0, errors.New("can't forget during a batch")
+	}
+
+	if !g.provenance {
+		g.lock.Unlock()
+		return 0, errors.New("provenance is not enabled")
+	}
+
+	tx, err := g.db.Begin()
+	if err != nil {
+		g.lock.Unlock()
+		return 0, err
+	}
+
+	lines, tokens, err := g.forgetAuthorTx(tx, author)
+	if err == nil {
+		err = tx.Commit()
+	} else {
+		tx.Rollback()
+	}
+
+	fuzzy, dist := g.fuzzy != nil, g.fuzzyDist
+	g.lock.Unlock()
+
+	// Deleted tokens have to leave the fuzzy index too.
+	if err == nil && tokens > 0 && fuzzy {
+		g.buildFuzzy(dist)
+	}
+
+	return lines, err
+}
+
+// forgetAuthorTx does the work of forgetAuthor in tx, returning the
+// number of lines forgotten and tokens deleted.
+func (g *graph) forgetAuthorTx(tx *sql.Tx, author string) (int, int, error) {
+	var lines int
+	err := tx.QueryRow("SELECT count(*) FROM learned_lines WHERE author = ?",
+		author).Scan(&lines)
+	if err != nil || lines == 0 {
+		return 0, 0, err
+	}
+
+	// Edges can be taught more than once by the same author.
+	rows, err := tx.Query("SELECT line_edges.edge_id, count(*) "+
+		"FROM learned_lines, line_edges "+
+		"WHERE learned_lines.author = ? "+
+		"AND line_edges.line_id = learned_lines.id "+
+		"GROUP BY line_edges.edge_id", author)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	taught := make(map[int64]int64)
+	for rows.Next() {
+		var edge, count int64
+		rows.Scan(&edge, &count)
+		taught[edge] = count
+	}
+	rows.Close()
+
+	if err = rows.Err(); err != nil {
+		return 0, 0, err
+	}
+
+	// Forget the lines' fingerprints while their edges still exist.
+	err = g.forgetFingerprints(tx, author)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	// The edges triggers keep node counts in step.
+	nodes := make(map[nodeID]bool)
+	for edge, count := range taught {
+		if g.halfLife > 0 {
+			err = g.forgetWeight(tx, edge, count)
+			if err != nil {
+				return 0, 0, err
+			}
+		}
+
+		_, err = tx.Exec("UPDATE edges SET count = max(count - ?, 0) "+
+			"WHERE id = ?", count, edge)
+		if err != nil {
+			return 0, 0, err
+		}
+
+		var prev, next nodeID
+		var left int64
+		err = tx.QueryRow("SELECT prev_node, next_node, count FROM edges "+
+			"WHERE id = ?", edge).Scan(&prev, &next, &left)
+		if err == sql.ErrNoRows {
+			continue
+		} else if err != nil {
+			return 0, 0, err
+		}
+
+		if left > 0 {
+			continue
+		}
+
+		_, err = tx.Exec("DELETE FROM edges WHERE id = ?", edge)
+		if err != nil {
+			return 0, 0, err
+		}
+
+		nodes[prev] = true
+		nodes[next] = true
+	}
+
+	_, err = tx.Exec("DELETE FROM line_edges WHERE line_id IN "+
+		"(SELECT id FROM learned_lines WHERE author = ?)", author)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	_, err = tx.Exec("DELETE FROM learned_lines WHERE author = ?", author)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	tokens, err := g.deleteOrphanNodes(tx, nodes)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	n, err := g.deleteOrphanTokens(tx, tokens)
+	return lines, n, err
+}
+
+// forgetFingerprints removes the dedupe hashes and novelty spans of
+// the lines author taught, so their text can be learned and their
+// sentences replied with again. Fingerprints that lines from other
+// authors share are kept.
+func (g *graph) forgetFingerprints(tx *sql.Tx, author string) error {
+	if g.dedupe == 0 && g.novelty == 0 {
+		return nil
+	}
+
+	forgot, err := g.lineFingerprints(tx, "SELECT id, text FROM learned_lines "+
+		"WHERE author = ?", author)
+	if err != nil {
+		return err
+	}
+
+	// Other authors' lines that share the text or an edge of one
+	// of author's lines may share its fingerprints.
+	kept, err := g.lineFingerprints(tx, "SELECT DISTINCT other.id, other.text "+
+		"FROM learned_lines mine, line_edges, line_edges shared, "+
+		"learned_lines other "+
+		"WHERE mine.author = ? AND line_edges.line_id = mine.id "+
+		"AND shared.edge_id = line_edges.edge_id "+
+		"AND other.id = shared.line_id AND other.author != mine.author "+
+		"UNION SELECT other.id, other.text "+
+		"FROM learned_lines mine, learned_lines other "+
+		"WHERE mine.author = ? AND other.text = mine.text "+
+		"AND other.author != mine.author", author, author)
+	if err != nil {
+		return err
+	}
+
+	for h := range forgot.hashes {
+		if kept.hashes[h] {
+			continue
+		}
+
+		_, err = tx.Exec("DELETE FROM learned_hashes WHERE hash = ?", h)
+		if err != nil {
+			return err
+		}
+	}
+
+	for h := range forgot.spans {
+		if kept.spans[h] {
+			continue
+		}
+
+		_, err = tx.Exec("DELETE FROM learned_spans WHERE hash = ?", h)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// fingerprints holds the dedupe hashes and novelty spans of some
+// learned lines.
+type fingerprints struct {
+	hashes map[int64]bool
+	spans  map[int64]bool
+}
+
+// lineFingerprints returns the fingerprints of the learned lines
+// selected by query, which returns their ids and text. Novelty spans
+// are rebuilt from the node path of each line's edges.
+func (g *graph) lineFingerprints(tx *sql.Tx, query string, args ...interface{}) (*fingerprints, error) {
+	ret := &fingerprints{make(map[int64]bool), make(map[int64]bool)}
+
+	rows, err := tx.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		var text string
+		rows.Scan(&id, &text)
+
+		ids = append(ids, id)
+		if g.dedupe > 0 {
+			ret.hashes[lineHash(text)] = true
+		}
+	}
+	rows.Close()
+
+	if err = rows.Err(); err != nil || g.novelty == 0 {
+		return ret, err
+	}
+
+	for _, id := range ids {
+		rows, err := tx.Query("SELECT edges.prev_node, edges.next_node "+
+			"FROM line_edges, edges WHERE line_edges.line_id = ? "+
+			"AND edges.id = line_edges.edge_id "+
+			"ORDER BY line_edges.rowid", id)
+		if err != nil {
+			return nil, err
+		}
+
+		var path []nodeID
+		for rows.Next() {
+			var prev, next nodeID
+			rows.Scan(&prev, &next)
+
+			if len(path) == 0 {
+				path = append(path, prev)
+			}
+			path = append(path, next)
+		}
+		rows.Close()
+
+		if err = rows.Err(); err != nil {
+			return nil, err
+		}
+
+		if len(path) == 0 {
+			continue
+		}
+
+		for _, h := range g.spans(path) {
+			ret.spans[h] = true
+		}
+	}
+
+	return ret, nil
+}
+
+// forgetWeight takes the share of n of its count from an edge's
+// weight, and the same from the weight of the node it leaves.
+func (g *graph) forgetWeight(tx *sql.Tx, edge int64, n int64) error {
+	var count, edgeSeen, nodeSeen int64
+	var prev nodeID
+	var edgeWeight, nodeWeight float64
+
+	err := tx.QueryRow("SELECT edges.count, edges.weight, edges.last_seen, "+
+		"edges.prev_node, nodes.weight, nodes.last_seen FROM edges, nodes "+
+		"WHERE edges.id = ? AND nodes.id = edges.prev_node", edge).Scan(
+		&count, &edgeWeight, &edgeSeen, &prev, &nodeWeight, &nodeSeen)
+	if err == sql.ErrNoRows || count == 0 {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	removed := edgeWeight * math.Min(float64(n)/float64(count), 1)
+
+	_, err = tx.Exec("UPDATE edges SET weight = ? WHERE id = ?",
+		edgeWeight-removed, edge)
+	if err != nil {
+		return err
+	}
+
+	nodeWeight -= decayed(removed, edgeSeen, nodeSeen, g.halfLife)
+	_, err = tx.Exec("UPDATE nodes SET weight = ? WHERE id = ?",
+		math.Max(nodeWeight, 0), prev)
+	return err
+}
+
+// deleteOrphanNodes deletes those of nodes that no edge uses anymore,
+// returning the tokens they held.
+func (g *graph) deleteOrphanNodes(tx *sql.Tx, nodes map[nodeID]bool) (map[tokenID]bool, error) {
+	cols := nStrings(g.order, func(i int) string {
+		return fmt.Sprintf("token%d_id", i)
+	})
+
+	selectTokens := fmt.Sprintf("SELECT %s FROM nodes WHERE id = ?",
+		strings.Join(cols, ", "))
+
+	tokens := make(map[tokenID]bool)
+	for node := range nodes {
+		if node == g.endContextID {
+			continue
+		}
+
+		var used bool
+		err := tx.QueryRow("SELECT EXISTS "+
+			"(SELECT 1 FROM edges WHERE prev_node = ?) OR EXISTS "+
+			"(SELECT 1 FROM edges WHERE next_node = ?)",
+			node, node).Scan(&used)
+		if err != nil {
+			return nil, err
+		}
+
+		if used {
+			continue
+		}
+
+		ids := make([]tokenID, g.order)
+		dest := make([]interface{}, g.order)
+		for i := range ids {
+			dest[i] = &ids[i]
+		}
+
+		err = tx.QueryRow(selectTokens, node).Scan(dest...)
+		if err != nil {
+			return nil, err
+		}
+
+		_, err = tx.Exec("DELETE FROM nodes WHERE id = ?", node)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, id := range ids {
+			tokens[id] = true
+		}
+	}
+
+	return tokens, nil
+}
+
+// deleteOrphanTokens deletes those of tokens that no node uses
+// anymore, along with their stems and folds. It returns the number
+// of tokens deleted.
+func (g *graph) deleteOrphanTokens(tx *sql.Tx, tokens map[tokenID]bool) (int, error) {
+	var uses []string
+	for i := 0; i < g.order; i++ {
+		uses = append(uses, fmt.Sprintf("token%d_id = ?", i))
+	}
+
+	selectUsed := fmt.Sprintf("SELECT EXISTS (SELECT 1 FROM nodes WHERE %s)",
+		strings.Join(uses, " OR "))
+
+	var deleted int
+	for token := range tokens {
+		if token == g.endTokenID {
+			continue
+		}
+
+		args := make([]interface{}, g.order)
+		for i := range args {
+			args[i] = token
+		}
+
+		var used bool
+		err := tx.QueryRow(selectUsed, args...).Scan(&used)
+		if err != nil {
+			return 0, err
+		}
+
+		if used {
+			continue
+		}
+
+		_, err = tx.Exec("DELETE FROM tokens WHERE id = ?", token)
+		if err != nil {
+			return 0, err
+		}
+
+		_, err = tx.Exec("DELETE FROM token_stems WHERE token_id = ?", token)
+		if err != nil {
+			return 0, err
+		}
+
+		if g.caseFold {
+			_, err = tx.Exec("DELETE FROM token_folds WHERE token_id = ?", token)
+			if err != nil {
+				return 0, err
+			}
+		}
+
+		deleted++
+	}
+
+	return deleted, nil
+}
+
+// getEdgeLines returns up to limit of the lines that taught the edge
+// from prev to next, most recent first.
+func (g *graph) getEdgeLines(prev nodeID, next nodeID, limit int) ([]LearnedLine, error) {
+	g.lock.RLock()
+	defer g.lock.RUnlock()
+
+	if !g.provenance {
+		return nil, errors.New("provenance is not enabled")
+	}
+
+	rows, err := g.q.selectEdgeLines.Query(prev, next, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ret []LearnedLine
+	for rows.Next() {
+		var line LearnedLine
+		var ts int64
+
+		err = rows.Scan(&line.Author, &line.Channel, &ts, &line.Text)
+		if err != nil {
+			return nil, err
+		}
+
+		if ts != 0 {
+			line.Timestamp = time.Unix(ts, 0)
+		}
+
+		ret = append(ret, line)
+	}
+
+	return ret, rows.Err()
+}
+
+// getEdgeAuthors returns the number of lines each author taught the
+// edge from prev to next.
+func (g *graph) getEdgeAuthors(prev nodeID, next nodeID) (map[string]int, error) {
+	g.lock.RLock()
+	defer g.lock.RUnlock()
+
+	if !g.provenance {
+		return nil, errors.New("provenance is not enabled")
+	}
+
+	rows, err := g.q.selectEdgeAuthors.Query(prev, next)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ret := make(map[string]int)
+	for rows.Next() {
+		var author string
+		var count int
+
+		err = rows.Scan(&author, &count)
+		if err != nil {
+			return nil, err
+		}
+
+		ret[author] = count
+	}
+
+	return ret, rows.Err()
+}
+
+// decayed returns weight, last updated at lastSeen, as of time t, both
+// in unix seconds. Weights decay by half every halfLife seconds.
+func decayed(weight float64, lastSeen int64, t int64, halfLife float64) float64 {
+	return weight * math.Exp2(-float64(t-lastSeen)/halfLife)
+}
+
+// addWeight adds one observation at time t to weight, returning the
+// new weight and last seen time. Observations older than lastSeen,
+// as when importing old logs, count for less instead of rewinding it.
+func addWeight(weight float64, lastSeen int64, t int64, halfLife float64) (float64, int64) {
+	if t < lastSeen {
+		return weight + math.Exp2(-float64(lastSeen-t)/halfLife), lastSeen
+	}
+
+	return decayed(weight, lastSeen, t, halfLife) + 1, t
+}
+
+// setDecay makes edge weights decay by half every halfLife. It adds
+// the weight and last_seen columns to edges and nodes if needed, and
+// starts every weight from its count when decay wasn't enabled. A
+// node's weight sums those of the edges leaving it.
+func (g *graph) setDecay(halfLife time.Duration) error {
+	if halfLife <= 0 {
+		return fmt.Errorf("invalid half-life: %s", halfLife)
+	}
+
+	g.lock.Lock()
+	var err error
+	if g.tx != nil {
+		err = errors.New("can't set decay during a batch")
+	} else if g.halfLife == 0 {
+		err = g.initWeights(time.Now().Unix())
+	}
+	if err == nil {
+		err = prepareDecaySql(g.db, g.q)
+	}
+	g.lock.Unlock()
+
+	if err != nil {
+		return err
+	}
+
+	err = g.setInfoString("half-life", halfLife.String())
+	if err != nil {
+		return err
+	}
+
+	g.lock.Lock()
+	g.halfLife = halfLife.Seconds()
+	g.lock.Unlock()
+
+	return nil
+}
+
+// initWeights sets every edge and node weight from the counts, as if
+// all were last seen at now.
+func (g *graph) initWeights(now int64) error {
+	var alters []string
+	for _, table := range []string{"edges", "nodes"} {
+		for _, col := range []string{
+			"weight REAL NOT NULL DEFAULT 0",
+			"last_seen INTEGER NOT NULL DEFAULT 0",
+		} {
+			ok, err := hasColumn(g.db, table, strings.Fields(col)[0])
+			if err != nil {
+				return err
+			}
+
+			if !ok {
+				alters = append(alters, fmt.Sprintf(
+					"ALTER TABLE %s ADD COLUMN %s", table, col))
+			}
+		}
+	}
+
+	tx, err := g.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, alter := range alters {
+		log.Printf("Migrating: %s", alter)
+		_, err = tx.Exec(alter)
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err = tx.Exec("UPDATE edges SET weight = count, last_seen = ?", now)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec("UPDATE nodes SET weight = (SELECT coalesce(sum(count), 0) "+
+		"FROM edges WHERE edges.prev_node = nodes.id), last_seen = ?", now)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// delDecay goes back to scoring by edge counts. The weight columns
+// are left behind, and restarted from the counts by setDecay.
+func (g *graph) delDecay() error {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	g.halfLife = 0
+
+	_, err := g.q.deleteInfo.Exec("half-life")
+	return err
+}
+
+// addEdgeWeight adds an observation at ts to the weight of the edge
+// from prev to next, and of prev. Call with the lock held.
+func (g *graph) addEdgeWeight(prev nodeID, next nodeID, hasSpace bool, ts time.Time) {
+	t := ts.Unix()
+
+	var weight float64
+	var lastSeen int64
+
+	err := g.q.selectEdgeWeight.QueryRow(prev, next, hasSpace).Scan(&weight, &lastSeen)
+	if err == nil {
+		weight, lastSeen = addWeight(weight, lastSeen, t, g.halfLife)
+		_, err = g.q.updateEdgeWeight.Exec(weight, lastSeen, prev, next, hasSpace)
+	}
+	if err != nil {
+		stats.Inc("error", 1, 1.0)
+		log.Printf("Updating edge weight: %s", err)
+	}
+
+	err = g.q.selectNodeWeight.QueryRow(prev).Scan(&weight, &lastSeen)
+	if err == nil {
+		weight, lastSeen = addWeight(weight, lastSeen, t, g.halfLife)
+		_, err = g.q.updateNodeWeight.Exec(weight, lastSeen, prev)
+	}
+	if err != nil {
+		stats.Inc("error", 1, 1.0)
+		log.Printf("Updating node weight: %s", err)
+	}
+}
+
+// getDecayedLogprob is getEdgeLogprob with decayed weights in place
+// of counts. Both decay at the same rate, so their ratio doesn't
+// depend on the current time.
+func (g *graph) getDecayedLogprob(prev nodeID, next nodeID) float64 {
+	g.lock.RLock()
+	defer g.lock.RUnlock()
+
+	var edgeWeight, nodeWeight float64
+	var edgeSeen, nodeSeen int64
+	err := g.q.selectEdgeWeights.QueryRow(prev, next).Scan(&edgeWeight,
+		&edgeSeen, &nodeWeight, &nodeSeen)
+	if err != nil {
+		log.Printf("Selecting edge weights: %s", err)
+	}
+
+	return math.Log2(edgeWeight) - math.Log2(nodeWeight) +
+		float64(edgeSeen-nodeSeen)/g.halfLife
+}
+
+// getDecayedWeights returns the weights of the edge from prev to next
+// and of prev, decayed to now.
+func (g *graph) getDecayedWeights(prev nodeID, next nodeID) (float64, float64) {
+	g.lock.RLock()
+	defer g.lock.RUnlock()
+
+	var edgeWeight, nodeWeight float64
+	var edgeSeen, nodeSeen int64
+	err := g.q.selectEdgeWeights.QueryRow(prev, next).Scan(&edgeWeight,
+		&edgeSeen, &nodeWeight, &nodeSeen)
+	if err != nil {
+		log.Printf("Selecting edge weights: %s", err)
+	}
+
+	now := time.Now().Unix()
+
+	return decayed(edgeWeight, edgeSeen, now, g.halfLife),
+		decayed(nodeWeight, nodeSeen, now, g.halfLife)
+}
+
+// followWeights returns the neighbors of node in direction dir and
+// the weights of the edges to them, decayed to now. Call with the
+// lock held.
+func (g *graph) followWeights(node nodeID, dir direction) ([]nodeID, []float64) {
+	q := g.q.fwdAdjWeights
+	if dir != forward {
+		q = g.q.revAdjWeights
+	}
+
+	rows, err := q.Query(node)
+	if err != nil {
+		return nil, nil
+	}
+	defer rows.Close()
+
+	now := time.Now().Unix()
+
+	var nodes []nodeID
+	var weights []float64
+
+	for rows.Next() {
+		var n, lastSeen int64
+		var weight float64
+		rows.Scan(&n, &weight, &lastSeen)
+
+		nodes = append(nodes, nodeID(n))
+		weights = append(weights, decayed(weight, lastSeen, now, g.halfLife))
+	}
+
+	return nodes, weights
+}
+
+// weightedPerm returns a random permutation of the indexes of
+// weights, where heavier indexes tend to come first: each is drawn
+// with probability proportional to its weight among those left.
+func weightedPerm(r *rand.Rand, weights []float64) []int {
+	keys := make([]float64, len(weights))
+	for i, w := range weights {
+		// Efraimidis and Spirakis: sort by u^(1/w), in logs.
+		keys[i] = math.Log(r.Float64()) / w
+	}
+
+	perm := make([]int, len(weights))
+	for i := range perm {
+		perm[i] = i
+	}
+
+	sort.SliceStable(perm, func(i, j int) bool {
+		return keys[perm[i]] > keys[perm[j]]
+	})
+
+	return perm
+}
+
+// minWeight keeps decayed weights from underflowing to zero.
+const minWeight = 1e-300
+
+// decay folds the decay since each edge and node was last seen into
+// its stored weight, as of now.
+func (g *graph) decay(now int64) error {
 	g.lock.Lock()
 	defer g.lock.Unlock()
 
-	res, err := g.q.updateInfo.Exec(key, value)
-	if err != nil {
-		stats.Inc("error", 1, 1.0)
-		return err
+	if g.halfLife == 0 {
+		return errors.New("decay is not enabled")
 	}
 
-	rows, err := res.RowsAffected()
+	if g.tx != nil {
+		return errors.New("can't decay during a batch")
+	}
+
+	tx, err := g.db.Begin()
 	if err != nil {
-		stats.Inc("error", 1, 1.0)
 		return err
 	}
+	defer tx.Rollback()
 
-	if rows > 0 {
-		return nil
+	for _, table := range []string{"edges", "nodes"} {
+		rows, err := tx.Query(fmt.Sprintf("SELECT id, weight, last_seen "+
+			"FROM %s WHERE last_seen < ?", table), now)
+		if err != nil {
+			return err
+		}
+
+		weights := make(map[int64]float64)
+		for rows.Next() {
+			var id, lastSeen int64
+			var weight float64
+			rows.Scan(&id, &weight, &lastSeen)
+
+			weights[id] = math.Max(decayed(weight, lastSeen, now, g.halfLife),
+				minWeight)
+		}
+		rows.Close()
+
+		if err = rows.Err(); err != nil {
+			return err
+		}
+
+		update := fmt.Sprintf("UPDATE %s SET weight = ?, last_seen = ? "+
+			"WHERE id = ?", table)
+		for id, weight := range weights {
+			_, err = tx.Exec(update, weight, now, id)
+			if err != nil {
+				return err
+			}
+		}
 	}
 
-	res, err = g.q.insertInfo.Exec(key, value)
+	return tx.Commit()
+}
+
+func (g *graph) delFuzzy() error {
+	g.lock.Lock()
+	g.fuzzy = nil
+	g.fuzzyDist = 0
+	g.lock.Unlock()
+
+	return g.delInfoString("fuzzy")
+}
+
+// setFuzzy enables matching unknown words to known ones within
+// maxDist edits.
+func (g *graph) setFuzzy(maxDist int) error {
+	if maxDist < 1 {
+		return fmt.Errorf("invalid fuzzy distance: %d", maxDist)
+	}
+
+	err := g.buildFuzzy(maxDist)
 	if err != nil {
-		stats.Inc("error", 1, 1.0)
 		return err
 	}
 
-	return nil
+	return g.setInfoString("fuzzy", strconv.Itoa(maxDist))
 }
 
-func (g *graph) delStemmer() error {
-	g.delInfoString("stemmer")
-	return g.deleteTokenStems()
-}
+func (g *graph) buildFuzzy(maxDist int) error {
+	g.lock.Lock()
+	defer g.lock.Unlock()
 
-func (g *graph) setStemmer(lang string) error {
-	snow, err := snowball.New(lang)
+	rows, err := g.db.Query("SELECT id, text FROM tokens WHERE is_word = 1")
 	if err != nil {
 		return err
 	}
+	defer rows.Close()
 
-	stemmer := newCobeStemmer(snow)
+	tree := &bkTree{}
+	for rows.Next() {
+		var id int64
+		var text string
 
-	g.deleteTokenStems()
-	g.updateTokenStems(stemmer)
-	g.setInfoString("stemmer", lang)
-	g.stemmer = stemmer
+		rows.Scan(&id, &text)
+		tree.add(foldCase(text), tokenID(id))
+	}
+
+	g.fuzzy = tree
+	g.fuzzyDist = maxDist
 
 	return nil
 }
 
-func (g *graph) deleteTokenStems() error {
+// addSynonyms records words as members of the equivalence class
+// named class. Words are compared case-insensitively.
+func (g *graph) addSynonyms(class string, words []string) error {
 	g.lock.Lock()
 	defer g.lock.Unlock()
 
 	var err error
 
-	_, err = g.db.Exec("DROP INDEX IF EXISTS token_stems_stem")
+	_, err = g.db.Exec(`
+CREATE TABLE IF NOT EXISTS synonyms (
+	word TEXT NOT NULL,
+	class TEXT NOT NULL,
+	UNIQUE (word, class))`)
 	if err != nil {
 		return err
 	}
 
-	_, err = g.db.Exec("DROP INDEX IF EXISTS token_stems_id")
+	_, err = g.db.Exec("CREATE INDEX IF NOT EXISTS synonyms_class " +
+		"ON synonyms (class)")
 	if err != nil {
 		return err
 	}
 
-	_, err = g.db.Exec("DELETE FROM token_stems")
-	return err
-}
-
-func (g *graph) updateTokenStems(s *cobeStemmer) error {
-	g.lock.Lock()
-	defer g.lock.Unlock()
-
-	rows, err := g.db.Query("SELECT id, text FROM tokens")
+	// Without case folding, synonym words are matched to tokens
+	// with selectTokenNocase.
+	_, err = g.db.Exec("CREATE INDEX IF NOT EXISTS tokens_text_nocase " +
+		"ON tokens (text COLLATE NOCASE)")
 	if err != nil {
 		return err
 	}
-	defer rows.Close()
 
-	for rows.Next() {
-		var id int64
-		var text string
+	if !g.synonyms {
+		err = prepareSynonymSql(g.db, g.q)
+		if err != nil {
+			return err
+		}
+	}
 
-		rows.Scan(&id, &text)
-		stem := s.Stem(text)
+	g.synonyms = true
 
-		if stem != "" {
-			g.q.insertStem.Exec(id, stem)
+	for _, word := range words {
+		_, err = g.db.Exec("INSERT OR IGNORE INTO synonyms (word, class) "+
+			"VALUES (?, ?)", foldCase(word), class)
+		if err != nil {
+			return err
 		}
 	}
 
 	return nil
 }
 
+func (g *graph) delSynonyms() error {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	g.synonyms = false
+
+	if g.q.selectSynonyms != nil {
+		g.q.selectSynonyms.Close()
+		g.q.selectSynonyms = nil
+	}
+
+	_, err := g.db.Exec("DROP INDEX IF EXISTS tokens_text_nocase")
+	if err != nil {
+		return err
+	}
+
+	_, err = g.db.Exec("DROP TABLE IF EXISTS synonyms")
+	return err
+}
+
 func index(haystack []string, needle string) int {
 	for i, s := range haystack {
 		if s == needle {
@@ -558,19 +2658,30 @@ func seqQ(n int) string {
 }
 
 func (g *graph) getOrCreateToken(text string) tokenID {
+	return g.getOrCreateClassToken(text, classifyToken(text))
+}
+
+// getOrCreateClassToken is getOrCreateToken for callers that know
+// the token's class, usually from the tokenizer.
+func (g *graph) getOrCreateClassToken(text string, class TokenClass) tokenID {
 	token, err := g.getTokenID(text)
 	if err == nil {
 		return token
 	}
 
-	var isWordRegexp = regexp.MustCompile(`\w`)
 	isWord := isWordRegexp.FindStringIndex(text) != nil
 
 	g.lock.Lock()
 	defer g.lock.Unlock()
 
 	stats.Inc("graph.token.created", 1, 1.0)
-	res, err := g.q.insertToken.Exec(text, isWord)
+
+	var res sql.Result
+	if g.tokenClasses {
+		res, err = g.q.insertToken.Exec(text, isWord, class)
+	} else {
+		res, err = g.q.insertToken.Exec(text, isWord)
+	}
 	if err != nil {
 		stats.Inc("error", 1, 1.0)
 		return -1
@@ -592,9 +2703,102 @@ func (g *graph) getOrCreateToken(text string) tokenID {
 		}
 	}
 
+	if g.fuzzy != nil && isWord {
+		g.fuzzy.add(foldCase(text), tokenID)
+	}
+
+	if g.caseFold {
+		fold := foldCase(text)
+		if fold != "" {
+			stats.Inc("graph.fold.created", 1, 1.0)
+			g.q.insertFold.Exec(tokenID, fold)
+		}
+	}
+
 	return tokenID
 }
 
+func (g *graph) getTokenText(token tokenID) string {
+	g.lock.RLock()
+	defer g.lock.RUnlock()
+
+	var text string
+
+	err := g.q.selectTokenText.QueryRow(token).Scan(&text)
+	if err != nil {
+		stats.Inc("error", 1, 1.0)
+		log.Printf("Selecting token text: %s", err)
+	}
+
+	return text
+}
+
+func (g *graph) getTokenClass(token tokenID) TokenClass {
+	g.lock.RLock()
+	defer g.lock.RUnlock()
+
+	return g.scanClass(g.q.selectTokenClass.QueryRow(token), "token")
+}
+
+// getNodeClass returns the class of the last token in node, which
+// is the token a reply takes from it.
+func (g *graph) getNodeClass(node nodeID) TokenClass {
+	g.lock.RLock()
+	defer g.lock.RUnlock()
+
+	return g.scanClass(g.q.selectNodeClass.QueryRow(node), "node")
+}
+
+// scanClass reads a class selected by selectTokenClass or
+// selectNodeClass, classifying the token text selected in its place
+// on brains without a class column.
+func (g *graph) scanClass(row *sql.Row, what string) TokenClass {
+	var err error
+	var class TokenClass
+
+	if g.tokenClasses {
+		var c int
+		err = row.Scan(&c)
+		class = TokenClass(c)
+	} else {
+		var text string
+		err = row.Scan(&text)
+		class = classifyToken(text)
+	}
+
+	if err != nil {
+		stats.Inc("error", 1, 1.0)
+		log.Printf("Selecting %s class: %s", what, err)
+	}
+
+	return class
+}
+
+// migrate brings an older brain's schema up to date, storing the
+// class of every token.
+func (g *graph) migrate() error {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	if g.tokenClasses {
+		return nil
+	}
+
+	err := migrateTokenClasses(g.db)
+	if err != nil {
+		return err
+	}
+
+	err = prepareClassSql(g.db, g.q, g.order, true)
+	if err != nil {
+		return err
+	}
+
+	g.tokenClasses = true
+
+	return nil
+}
+
 func toQueryArgs(tokenIds []tokenID) []interface{} {
 	ret := make([]interface{}, 0, len(tokenIds))
 	for _, tokenID := range tokenIds {
@@ -633,7 +2837,8 @@ func (g *graph) getOrCreateNode(tokens []tokenID) nodeID {
 	return nodeID(node)
 }
 
-func (g *graph) addEdge(prev nodeID, next nodeID, hasSpace bool) {
+// addEdge counts the edge from prev to next, seen at time ts.
+func (g *graph) addEdge(prev nodeID, next nodeID, hasSpace bool, ts time.Time) {
 	g.lock.Lock()
 	defer g.lock.Unlock()
 
@@ -662,6 +2867,10 @@ func (g *graph) addEdge(prev nodeID, next nodeID, hasSpace bool) {
 	// incremented here with database triggers. This registers
 	// that the node has been seen an additional time (used by
 	// scoring).
+
+	if g.halfLife > 0 {
+		g.addEdgeWeight(prev, next, hasSpace, ts)
+	}
 }
 
 func (g *graph) getTextByNodes(prev nodeID, next nodeID) (string, bool, error) {
@@ -727,22 +2936,179 @@ func (g *graph) getTokensByStem(stem string) []tokenID {
 	return ret
 }
 
-func (g *graph) getEdgeLogprob(prev nodeID, next nodeID) float64 {
+// getTokensByFold returns the tokens whose text matches text after
+// case folding.
+func (g *graph) getTokensByFold(text string) []tokenID {
+	g.lock.RLock()
+	defer g.lock.RUnlock()
+
+	var ret []tokenID
+
+	if !g.caseFold {
+		return ret
+	}
+
+	rows, err := g.q.selectFoldTokens.Query(foldCase(text))
+	if err != nil {
+		stats.Inc("error", 1, 1.0)
+		log.Printf("Selecting fold tokens: %s", err)
+		return ret
+	}
+	defer rows.Close()
+
+	var t int64
+	for rows.Next() {
+		rows.Scan(&t)
+		ret = append(ret, tokenID(t))
+	}
+
+	return ret
+}
+
+// getTokensByLangStem returns the tokens that share a stem with text
+// in lang.
+func (g *graph) getTokensByLangStem(text string, lang string) []tokenID {
+	g.lock.RLock()
+	defer g.lock.RUnlock()
+
+	var ret []tokenID
+
+	s := g.langStemmers[lang]
+	if g.languages == nil || s == nil {
+		return ret
+	}
+
+	rows, err := g.q.selectLangStemTokens.Query(s.Stem(text), lang)
+	if err != nil {
+		stats.Inc("error", 1, 1.0)
+		log.Printf("Selecting stem tokens: %s", err)
+		return ret
+	}
+	defer rows.Close()
+
+	var t int64
+	for rows.Next() {
+		rows.Scan(&t)
+		ret = append(ret, tokenID(t))
+	}
+
+	return ret
+}
+
+// getTokensBySynonym returns the known tokens that share an
+// equivalence class with text.
+func (g *graph) getTokensBySynonym(text string) []tokenID {
+	g.lock.RLock()
+	defer g.lock.RUnlock()
+
+	if !g.synonyms {
+		return nil
+	}
+
+	rows, err := g.q.selectSynonyms.Query(foldCase(text))
+	if err != nil {
+		stats.Inc("error", 1, 1.0)
+		log.Printf("Selecting synonyms: %s", err)
+		return nil
+	}
+
+	var words []string
+	for rows.Next() {
+		var word string
+		rows.Scan(&word)
+		words = append(words, word)
+	}
+	rows.Close()
+
+	// Synonyms are stored folded, so match them against tokens the
+	// same way. Without the fold index, fall back to SQLite's NOCASE,
+	// which only folds ASCII.
+	q := g.q.selectTokenNocase
+	if g.caseFold {
+		q = g.q.selectFoldTokens
+	}
+
+	var ret []tokenID
+	for _, word := range words {
+		rows, err := q.Query(word)
+		if err != nil {
+			stats.Inc("error", 1, 1.0)
+			log.Printf("Selecting synonym tokens: %s", err)
+			continue
+		}
+
+		var t int64
+		for rows.Next() {
+			rows.Scan(&t)
+			ret = append(ret, tokenID(t))
+		}
+		rows.Close()
+	}
+
+	return ret
+}
+
+// getTokensByFuzzy returns the known words nearest to text by edit
+// distance.
+func (g *graph) getTokensByFuzzy(text string) []tokenID {
 	g.lock.RLock()
 	defer g.lock.RUnlock()
 
+	if g.fuzzy == nil || !fuzzyCandidate(text, g.fuzzyDist) {
+		return nil
+	}
+
+	return g.fuzzy.nearest(foldCase(text), g.fuzzyDist)
+}
+
+func (g *graph) getEdgeLogprob(prev nodeID, next nodeID) float64 {
 	// Each edges goes from an n-gram node (word1, word2, word3)
 	// to another (word2, word3, word4).
 	//
 	// P(word4|word1, word2, word3) = count(edgeId) / count(prevNodeId)
 	//
+	if g.halfLife > 0 {
+		return g.getDecayedLogprob(prev, next)
+	}
+
+	edgeCount, prevNodeCount := g.getEdgeCounts(prev, next)
+
+	return math.Log2(float64(edgeCount)) - math.Log2(float64(prevNodeCount))
+}
+
+// getEdgeCounts returns the count of the edge from prev to next, and
+// the count of prev.
+func (g *graph) getEdgeCounts(prev nodeID, next nodeID) (int64, int64) {
+	g.lock.RLock()
+	defer g.lock.RUnlock()
+
 	var edgeCount, prevNodeCount int64
 	err := g.q.selectEdgeCounts.QueryRow(prev, next).Scan(&edgeCount, &prevNodeCount)
 	if err != nil {
 		log.Printf("Selecting edge counts: %s", err)
 	}
 
-	return math.Log2(float64(edgeCount)) - math.Log2(float64(prevNodeCount))
+	return edgeCount, prevNodeCount
+}
+
+// getNodeTokens returns the text of the tokens in node.
+func (g *graph) getNodeTokens(node nodeID) []string {
+	g.lock.RLock()
+	defer g.lock.RUnlock()
+
+	tokens := make([]string, g.order)
+	dest := make([]interface{}, g.order)
+	for i := range tokens {
+		dest[i] = &tokens[i]
+	}
+
+	err := g.q.selectNodeTokens.QueryRow(node).Scan(dest...)
+	if err != nil {
+		stats.Inc("error", 1, 1.0)
+		log.Printf("Selecting node tokens: %s", err)
+	}
+
+	return tokens
 }
 
 type node struct {
@@ -751,7 +3117,9 @@ type node struct {
 }
 
 type search struct {
-	follow func(node nodeID) []nodeID
+	// follow returns the neighbors of node and, if the graph
+	// tracks recency, their decayed edge weights.
+	follow func(node nodeID) ([]nodeID, []float64)
 	rand   *rand.Rand
 	end    nodeID
 	left   *list.List
@@ -772,9 +3140,14 @@ loop:
 		case <-s.stop:
 			break loop
 		default:
-			nodes := s.follow(cur.node)
+			nodes, weights := s.follow(cur.node)
+
+			order := s.rand.Perm(len(nodes))
+			if weights != nil {
+				order = weightedPerm(s.rand, weights)
+			}
 
-			for _, i := range s.rand.Perm(len(nodes)) {
+			for _, i := range order {
 				s.left.PushBack(&node{nodes[i], cur})
 			}
 		}
@@ -818,13 +3191,17 @@ func (g *graph) search(start nodeID, end nodeID, dir direction, stop <-chan bool
 		q = g.q.revAdj
 	}
 
-	follow := func(node nodeID) []nodeID {
+	follow := func(node nodeID) ([]nodeID, []float64) {
 		g.lock.RLock()
 		defer g.lock.RUnlock()
 
+		if g.halfLife > 0 {
+			return g.followWeights(node, dir)
+		}
+
 		rows, err := q.Query(node)
 		if err != nil {
-			return nil
+			return nil, nil
 		}
 		defer rows.Close()
 
@@ -837,7 +3214,7 @@ func (g *graph) search(start nodeID, end nodeID, dir direction, stop <-chan bool
 			nodes = append(nodes, nodeID(n))
 		}
 
-		return nodes
+		return nodes, nil
 	}
 
 	r := rand.New(rand.NewSource(time.Now().UnixNano()))
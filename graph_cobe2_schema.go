@@ -40,7 +40,8 @@ CREATE TABLE info (
 CREATE TABLE tokens (
 	id INTEGER PRIMARY KEY AUTOINCREMENT,
 	text TEXT UNIQUE NOT NULL,
-	is_word INTEGER NOT NULL)`)
+	is_word INTEGER NOT NULL,
+	class INTEGER NOT NULL DEFAULT 0)`)
 
 	if err != nil {
 		return err
@@ -107,3 +108,100 @@ CREATE TRIGGER IF NOT EXISTS edges_delete_trigger AFTER DELETE ON edges
 
 	return nil
 }
+
+// migrateLearnedLines adds the text column to the learned_lines table
+// of brains that recorded provenance before attribution existed, and
+// indexes line_edges by edge for Explain.
+func migrateLearnedLines(db *sql.DB) error {
+	ok, err := hasColumn(db, "learned_lines", "text")
+	if err != nil {
+		return err
+	}
+
+	if !ok {
+		log.Println("Migrating table: learned_lines (adding text)")
+
+		_, err = db.Exec(
+			"ALTER TABLE learned_lines ADD COLUMN text TEXT NOT NULL DEFAULT ''")
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err = db.Exec("CREATE INDEX IF NOT EXISTS line_edges_edge " +
+		"ON line_edges (edge_id)")
+	return err
+}
+
+// migrateTokenClasses adds the class column to the tokens table of
+// brains created before token classes existed, and classifies all
+// the tokens already learned.
+func migrateTokenClasses(db *sql.DB) error {
+	ok, err := hasColumn(db, "tokens", "class")
+	if err != nil || ok {
+		return err
+	}
+
+	log.Println("Migrating table: tokens (adding class)")
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(
+		"ALTER TABLE tokens ADD COLUMN class INTEGER NOT NULL DEFAULT 0")
+	if err != nil {
+		return err
+	}
+
+	rows, err := tx.Query("SELECT id, text FROM tokens")
+	if err != nil {
+		return err
+	}
+
+	classes := make(map[int64]TokenClass)
+	for rows.Next() {
+		var id int64
+		var text string
+
+		rows.Scan(&id, &text)
+		classes[id] = classifyToken(text)
+	}
+	rows.Close()
+
+	for id, class := range classes {
+		_, err = tx.Exec("UPDATE tokens SET class = ? WHERE id = ?", class, id)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func hasColumn(db *sql.DB, table, column string) (bool, error) {
+	rows, err := db.Query(fmt.Sprintf("PRAGMA table_info(%s)", table))
+	if err != nil {
+		return false, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var cid, notNull, pk int
+		var name, ctype string
+		var dflt interface{}
+
+		err = rows.Scan(&cid, &name, &ctype, &notNull, &dflt, &pk)
+		if err != nil {
+			return false, err
+		}
+
+		if name == column {
+			return true, nil
+		}
+	}
+
+	return false, rows.Err()
+}
@@ -1,6 +1,7 @@
 package cobe
 
 import (
+	"fmt"
 	"io"
 	"io/ioutil"
 	"os"
@@ -24,6 +25,27 @@ func tmpCopy(src string) (string, error) {
 	return df.Name(), nil
 }
 
+// newTestBrain creates an empty brain in a temporary file.
+func newTestBrain(t *testing.T) (*Cobe2Brain, string) {
+	tmp, err := ioutil.TempFile("", "tests")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmp.Close()
+
+	err = initGraph(tmp.Name(), defaultGraphOptions)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := OpenCobe2Brain(tmp.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return b, tmp.Name()
+}
+
 func TestInit(t *testing.T) {
 	tmp, err := ioutil.TempFile("", "tests")
 	if err != nil {
@@ -71,6 +93,16 @@ func TestInfo(t *testing.T) {
 	if text != "bar" || err != nil {
 		t.Errorf("Expected bar, was %s", text)
 	}
+
+	err = g.setInfoString("foo", "baz")
+	if err != nil {
+		t.Error(err)
+	}
+
+	text, err = g.getInfoString("foo")
+	if text != "baz" || err != nil {
+		t.Errorf("Expected baz, was %s", text)
+	}
 }
 
 func TestAlice(t *testing.T) {
@@ -173,3 +205,103 @@ func TestGetTextByNode(t *testing.T) {
 		t.Errorf("Expected . & true, got %s & %t", word, hasSpace)
 	}
 }
+
+func TestCaseFold(t *testing.T) {
+	filename, err := tmpCopy("data/pg11.brain")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer os.Remove(filename)
+
+	g, err := openGraph(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer g.close()
+
+	if ids := g.getTokensByFold("ALICE"); len(ids) != 0 {
+		t.Errorf("Expected no fold tokens before setCaseFold, got %v", ids)
+	}
+
+	err = g.setCaseFold()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ids := g.getTokensByFold("ALICE")
+	if len(ids) != 1 || ids[0] != 18 {
+		t.Errorf("Expected ALICE to fold to [18], was %v", ids)
+	}
+
+	token := g.getOrCreateToken("Platypus")
+	ids = g.getTokensByFold("platypus")
+	if len(ids) != 1 || ids[0] != token {
+		t.Errorf("Expected platypus to fold to [%d], was %v", token, ids)
+	}
+
+	err = g.delCaseFold()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if ids := g.getTokensByFold("ALICE"); len(ids) != 0 {
+		t.Errorf("Expected no fold tokens after delCaseFold, got %v", ids)
+	}
+
+	if g.q.insertFold != nil || g.q.selectFoldTokens != nil {
+		t.Error("Expected fold statements to be closed after delCaseFold")
+	}
+
+	err = g.setCaseFold()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ids = g.getTokensByFold("ALICE")
+	if len(ids) != 1 || ids[0] != 18 {
+		t.Errorf("Expected ALICE to fold to [18] again, was %v", ids)
+	}
+}
+
+func TestTokenClasses(t *testing.T) {
+	filename, err := tmpCopy("data/pg11.brain")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer os.Remove(filename)
+
+	// pg11.brain predates token classes. Opening it leaves the
+	// tokens table alone, and classes come from token text until
+	// it's migrated.
+	g, err := openGraph(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer g.close()
+
+	for _, migrated := range []bool{false, true} {
+		if ok, _ := hasColumn(g.db, "tokens", "class"); ok != migrated {
+			t.Errorf("Expected class column %v, was %v", migrated, ok)
+		}
+
+		if class := g.getTokenClass(18); class != WordToken {
+			t.Errorf("Expected Alice to be a word, was %s", class)
+		}
+
+		if class := g.getTokenClass(11); class != PunctuationToken {
+			t.Errorf("Expected . to be punctuation, was %s", class)
+		}
+
+		token := g.getOrCreateToken(fmt.Sprintf("http://example.com/%v", migrated))
+		if class := g.getTokenClass(token); class != URLToken {
+			t.Errorf("Expected a url, was %s", class)
+		}
+
+		err = g.migrate()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"log"
 	"regexp"
+	"sort"
 	"strings"
 	"time"
 
@@ -16,6 +17,20 @@ type Options struct {
 	Nick     string
 	Channels []string
 	Ignore   []string
+
+	// Admins are the nicks allowed to use admin commands, like
+	// "!why" to see whose lines the last reply came from. Nicks
+	// aren't authenticated, so only use this on networks that
+	// protect registered nicks.
+	Admins []string
+
+	// Decay is how often to run the brain's Decay maintenance, or
+	// 0 not to. See cobe.Cobe2Brain.SetDecay.
+	Decay time.Duration
+
+	// Scrub says what to do with personal data in the messages
+	// the bot learns. The zero value is cobe.ScrubReplace.
+	Scrub cobe.ScrubMode
 }
 
 // Backoff policy, milliseconds per attempt. End up with 30s attempts.
@@ -46,6 +61,22 @@ func backoffConnect(conn *irc.Conn, o *Options) {
 }
 
 func RunForever(b *cobe.Cobe2Brain, o *Options) {
+	scrub := o.Scrub
+	if scrub == 0 {
+		scrub = cobe.ScrubReplace
+	}
+	b.SetScrub(scrub)
+
+	if o.Decay > 0 {
+		go func() {
+			for range time.Tick(o.Decay) {
+				if err := b.Decay(); err != nil {
+					log.Printf("Decay: %s", err)
+				}
+			}
+		}()
+	}
+
 	stop := make(chan bool)
 	conn := irc.SimpleClient(o.Nick)
 
@@ -75,9 +106,8 @@ func RunForever(b *cobe.Cobe2Brain, o *Options) {
 		}
 	})
 
-	// The space after comma/colon is needed so we won't treat
-	// urls as messages spoken to http.
-	userMsg := regexp.MustCompile(`^(\S+)[,:]\s(.*?)$`)
+	// The last reply in each channel, for !why.
+	lastReplies := make(map[string]*cobe.Reply)
 
 	conn.HandleFunc("privmsg", func(conn *irc.Conn, line *irc.Line) {
 		user := line.Nick
@@ -92,23 +122,32 @@ func RunForever(b *cobe.Cobe2Brain, o *Options) {
 			return
 		}
 
-		var to, msg string
+		to, msg := SplitAddressee(line.Args[1])
 
-		groups := userMsg.FindStringSubmatch(line.Args[1])
-		if len(groups) > 0 {
-			to = groups[1]
-			msg = groups[2]
-		} else {
-			msg = line.Args[1]
+		if to == o.Nick && msg == "!why" && in(o.Admins, user) {
+			for _, text := range explain(b, lastReplies[target]) {
+				conn.Privmsg(user, text)
+			}
+			return
 		}
 
-		msg = strings.TrimSpace(msg)
-
 		log.Printf("Learn: %s", msg)
-		b.Learn(msg)
+		b.LearnRecord(cobe.LearnRecord{
+			Text:      msg,
+			Author:    user,
+			Channel:   target,
+			Timestamp: time.Now(),
+		})
 
 		if to == o.Nick {
-			reply := b.Reply(msg)
+			reply := b.BestReply(msg, cobe.DefaultReplyOptions)
+			if reply == nil {
+				conn.Privmsg(target, fmt.Sprintf("%s: %s", user,
+					"I don't know enough to answer you yet!"))
+				return
+			}
+
+			lastReplies[target] = reply
 			log.Printf("Reply: %s", reply)
 			conn.Privmsg(target, fmt.Sprintf("%s: %s", user, reply))
 		}
@@ -118,6 +157,76 @@ func RunForever(b *cobe.Cobe2Brain, o *Options) {
 	<-stop
 }
 
+// explainSamples limits the example lines explain sends.
+const explainSamples = 3
+
+// explain summarizes whose lines a reply came from in a few messages:
+// the authors, by the number of its words they taught, and a sample
+// of their lines.
+func explain(b *cobe.Cobe2Brain, r *cobe.Reply) []string {
+	sources, err := b.Explain(r)
+	if err != nil {
+		return []string{fmt.Sprintf("Can't explain: %s", err)}
+	}
+
+	counts := make(map[string]int)
+	var authors []string
+	var samples []string
+
+	for _, src := range sources {
+		for author := range src.Authors {
+			if counts[author] == 0 {
+				authors = append(authors, author)
+			}
+			counts[author]++
+		}
+
+		for _, line := range src.Lines {
+			if len(samples) < explainSamples && !in(samples, line.Author+": "+line.Text) {
+				samples = append(samples, line.Author+": "+line.Text)
+			}
+		}
+	}
+
+	if len(authors) == 0 {
+		return []string{fmt.Sprintf("No known sources for: %s", r)}
+	}
+
+	sort.Slice(authors, func(i, j int) bool {
+		if counts[authors[i]] != counts[authors[j]] {
+			return counts[authors[i]] > counts[authors[j]]
+		}
+		return authors[i] < authors[j]
+	})
+
+	var parts []string
+	for _, author := range authors {
+		parts = append(parts, fmt.Sprintf("%s (%d)", author, counts[author]))
+	}
+
+	ret := []string{fmt.Sprintf("%s <- %s", r, strings.Join(parts, ", "))}
+	return append(ret, samples...)
+}
+
+// The space after comma/colon is needed so we won't treat urls as
+// messages spoken to http.
+var userMsg = regexp.MustCompile(`^(\S+)[,:]\s(.*?)$`)
+
+// SplitAddressee splits a channel message like "cobe: hello" into the
+// nick it's addressed to and the message. Messages that aren't
+// addressed to anyone have an empty nick.
+func SplitAddressee(text string) (to string, msg string) {
+	groups := userMsg.FindStringSubmatch(text)
+	if len(groups) > 0 {
+		to = groups[1]
+		msg = groups[2]
+	} else {
+		msg = text
+	}
+
+	return to, strings.TrimSpace(msg)
+}
+
 func in(haystack []string, needle string) bool {
 	for _, h := range haystack {
 		if h == needle {
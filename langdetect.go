@@ -0,0 +1,211 @@
+package cobe
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// langDetector guesses the language of a line of text by comparing
+// its character trigrams to trigram profiles of each language. The
+// profiles are built from the samples in langSamples, so detection
+// works offline.
+type langDetector struct {
+	langs    []string
+	profiles map[string]*langProfile
+}
+
+type langProfile struct {
+	counts map[string]int
+	total  int
+}
+
+func newLangDetector(langs []string) (*langDetector, error) {
+	d := &langDetector{langs, make(map[string]*langProfile)}
+
+	for _, lang := range langs {
+		sample, ok := langSamples[lang]
+		if !ok {
+			return nil, fmt.Errorf("no language profile for %s", lang)
+		}
+
+		p := &langProfile{counts: make(map[string]int)}
+		for _, t := range trigrams(sample) {
+			p.counts[t]++
+			p.total++
+		}
+
+		d.profiles[lang] = p
+	}
+
+	return d, nil
+}
+
+// langMargin is how much more likely, as a log probability, another
+// language must be than the first before detect picks it. The
+// profiles are small, so short or unusual lines often score a little
+// better in the wrong language.
+const langMargin = 5.0
+
+// detect returns the most likely language of text. Text too short
+// to tell, or not clearly in another language, is assumed to be in
+// the first language.
+func (d *langDetector) detect(text string) string {
+	grams := trigrams(text)
+	if len(grams) < 3 {
+		return d.langs[0]
+	}
+
+	best := d.langs[0]
+	bestScore := math.Inf(-1)
+	firstScore := math.Inf(-1)
+
+	for _, lang := range d.langs {
+		p := d.profiles[lang]
+
+		// Add-one smoothing keeps unseen trigrams from ruling
+		// out a language entirely.
+		var score float64
+		for _, t := range grams {
+			score += math.Log(float64(p.counts[t]+1) / float64(p.total+len(p.counts)))
+		}
+
+		if lang == d.langs[0] {
+			firstScore = score
+		}
+
+		if score > bestScore {
+			best = lang
+			bestScore = score
+		}
+	}
+
+	if bestScore-firstScore < langMargin {
+		return d.langs[0]
+	}
+
+	return best
+}
+
+// trigrams returns the letter trigrams of each word in text, padded
+// with spaces so word beginnings and endings count.
+func trigrams(text string) []string {
+	var ret []string
+
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && r != '\''
+	})
+
+	for _, word := range words {
+		r := []rune(" " + word + " ")
+		for i := 0; i+3 <= len(r); i++ {
+			ret = append(ret, string(r[i:i+3]))
+		}
+	}
+
+	return ret
+}
+
+// Languages returns the languages that can be detected with
+// SetLanguages.
+func Languages() []string {
+	var ret []string
+	for lang := range langSamples {
+		ret = append(ret, lang)
+	}
+
+	sort.Strings(ret)
+	return ret
+}
+
+// langSamples holds everyday text in each detectable language. The
+// keys are snowball language names.
+var langSamples = map[string]string{
+	"english": `
+I don't know what you mean, but it sounds like a good idea to me.
+What are you doing this weekend? We should go out and have something to
+eat. The weather has been really nice lately and I think it will stay
+that way for a while. Have you seen the new film yet? My brother said it
+was the best thing he had watched all year, although he says that about
+everything. There is a problem with the server again, so nobody can log
+in right now. Could you please tell them that we will be late? It was
+the first time that anyone in the house had heard of it, and they were
+all very surprised. Which one would you like, the red or the blue? This
+is not what I expected when I woke up this morning.`,
+
+	"german": `
+Ich weiß nicht, was du meinst, aber das klingt nach einer guten Idee.
+Was machst du an diesem Wochenende? Wir sollten zusammen essen gehen.
+Das Wetter war in letzter Zeit wirklich schön und ich glaube, es bleibt
+noch eine Weile so. Hast du den neuen Film schon gesehen? Mein Bruder
+sagt, es war das Beste, was er dieses Jahr gesehen hat, aber das sagt er
+über alles. Es gibt wieder ein Problem mit dem Server, deshalb kann sich
+gerade niemand anmelden. Könntest du ihnen bitte sagen, dass wir später
+kommen? Es war das erste Mal, dass jemand im Haus davon gehört hatte, und
+alle waren sehr überrascht. Welchen möchtest du, den roten oder den
+blauen? Das ist nicht, was ich erwartet habe, als ich heute Morgen
+aufgewacht bin.`,
+
+	"spanish": `
+No sé lo que quieres decir, pero me parece una buena idea. ¿Qué vas a
+hacer este fin de semana? Deberíamos salir a comer algo. El tiempo ha
+estado muy bien últimamente y creo que seguirá así durante un tiempo.
+¿Ya has visto la nueva película? Mi hermano dijo que era lo mejor que
+había visto en todo el año, aunque él dice eso de todo. Hay otra vez un
+problema con el servidor, así que nadie puede entrar ahora mismo.
+¿Podrías decirles que vamos a llegar tarde, por favor? Era la primera
+vez que alguien en la casa había oído hablar de eso, y todos estaban muy
+sorprendidos. ¿Cuál quieres, el rojo o el azul? Esto no es lo que
+esperaba cuando me desperté esta mañana.`,
+
+	"french": `
+Je ne sais pas ce que tu veux dire, mais ça me semble être une bonne
+idée. Qu'est-ce que tu fais ce week-end? On devrait sortir manger
+quelque chose. Il fait vraiment beau depuis quelque temps et je pense
+que ça va durer encore un peu. Tu as déjà vu le nouveau film? Mon frère
+a dit que c'était la meilleure chose qu'il avait vue de l'année, mais il
+dit ça de tout. Il y a encore un problème avec le serveur, donc personne
+ne peut se connecter pour le moment. Tu pourrais leur dire que nous
+serons en retard, s'il te plaît? C'était la première fois que quelqu'un
+dans la maison en entendait parler, et ils étaient tous très surpris.
+Lequel veux-tu, le rouge ou le bleu? Ce n'est pas ce que j'attendais en
+me réveillant ce matin.`,
+
+	"italian": `
+Non so cosa vuoi dire, ma mi sembra una buona idea. Cosa fai questo fine
+settimana? Dovremmo uscire a mangiare qualcosa. Il tempo è stato davvero
+bello ultimamente e penso che resterà così ancora per un po'. Hai già
+visto il nuovo film? Mio fratello ha detto che era la cosa migliore che
+avesse visto quest'anno, anche se lo dice di tutto. C'è di nuovo un
+problema con il server, quindi nessuno può entrare in questo momento.
+Potresti dire loro che arriveremo tardi, per favore? Era la prima volta
+che qualcuno in casa ne sentiva parlare, ed erano tutti molto sorpresi.
+Quale vuoi, il rosso o il blu? Non è quello che mi aspettavo quando mi
+sono svegliato stamattina.`,
+
+	"dutch": `
+Ik weet niet wat je bedoelt, maar het klinkt als een goed idee. Wat ga
+je dit weekend doen? We zouden samen iets moeten gaan eten. Het weer is
+de laatste tijd echt mooi en ik denk dat het nog wel een tijdje zo
+blijft. Heb je de nieuwe film al gezien? Mijn broer zei dat het het
+beste was wat hij dit jaar had gezien, maar dat zegt hij over alles. Er
+is weer een probleem met de server, dus niemand kan nu inloggen. Kun je
+ze alsjeblieft vertellen dat we later komen? Het was de eerste keer dat
+iemand in het huis ervan had gehoord, en ze waren allemaal erg verrast.
+Welke wil je, de rode of de blauwe? Dit is niet wat ik verwachtte toen
+ik vanochtend wakker werd.`,
+
+	"portuguese": `
+Não sei o que você quer dizer, mas me parece uma boa ideia. O que você
+vai fazer neste fim de semana? Devíamos sair para comer alguma coisa. O
+tempo tem estado muito bom ultimamente e acho que vai continuar assim
+por um tempo. Você já viu o filme novo? Meu irmão disse que foi a melhor
+coisa que ele viu no ano todo, mas ele diz isso de tudo. Há outra vez um
+problema com o servidor, então ninguém consegue entrar agora. Você
+poderia dizer a eles que vamos chegar atrasados, por favor? Foi a
+primeira vez que alguém na casa tinha ouvido falar disso, e todos
+ficaram muito surpresos. Qual você quer, o vermelho ou o azul? Isso não
+é o que eu esperava quando acordei hoje de manhã.`,
+}
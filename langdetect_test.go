@@ -0,0 +1,92 @@
+package cobe
+
+import (
+	"os"
+	"testing"
+)
+
+func TestLangDetector(t *testing.T) {
+	d, err := newLangDetector([]string{"english", "german", "spanish", "french"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var tests = []struct {
+		text     string
+		expected string
+	}{
+		{"the cat is sleeping on the chair in the kitchen", "english"},
+		{"die Katze schläft auf dem Stuhl in der Küche", "german"},
+		{"el gato está durmiendo en la silla de la cocina", "spanish"},
+		{"le chat dort sur la chaise dans la cuisine", "french"},
+		{"where are my keys? I can't find them anywhere", "english"},
+		{"wo sind meine Schlüssel? ich kann sie nicht finden", "german"},
+		{"¿dónde están mis llaves? no las encuentro", "spanish"},
+		{"où sont mes clés? je ne les trouve nulle part", "french"},
+
+		// Too short to tell: the first language wins.
+		{"ok", "english"},
+
+		// Not clearly another language: the first language wins.
+		{"ok cool", "english"},
+		{"nice one dude", "english"},
+		{"git push origin master", "english"},
+	}
+
+	for ti, tt := range tests {
+		lang := d.detect(tt.text)
+		if lang != tt.expected {
+			t.Errorf("[%d] %s: expected %s, was %s", ti, tt.text,
+				tt.expected, lang)
+		}
+	}
+
+	_, err = newLangDetector([]string{"klingon"})
+	if err == nil {
+		t.Error("Expected an error for an unknown language")
+	}
+}
+
+func TestLangStems(t *testing.T) {
+	filename, err := tmpCopy("data/pg11.brain")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer os.Remove(filename)
+
+	b, err := OpenCobe2Brain(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Close()
+
+	err = b.SetLanguages("english", "spanish")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Existing tokens are stemmed in both languages.
+	rabbit, _ := b.graph.getTokenID("rabbit")
+	if ids := b.graph.getTokensByLangStem("rabbits", "english"); !containsId(ids, rabbit) {
+		t.Errorf("Expected rabbits to stem like rabbit, got %v", ids)
+	}
+
+	b.Learn("el conejo blanco estaba corriendo por el jardín")
+
+	corriendo, err := b.graph.getTokenID("corriendo")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ids := b.conflateStems("los conejos corren por el jardín", []string{"corren"})
+	if !containsId(ids, corriendo) {
+		t.Errorf("Expected corren to stem like corriendo, got %v", ids)
+	}
+
+	// English input isn't stemmed as Spanish.
+	ids = b.conflateStems("the rabbits corren", []string{"corren"})
+	if containsId(ids, corriendo) {
+		t.Errorf("Expected no Spanish stems for English input, got %v", ids)
+	}
+}
@@ -0,0 +1,113 @@
+package cobe
+
+import (
+	"regexp"
+)
+
+// A LearnFilter decides whether a line should be learned. It's given
+// the line's text and tokens, without spaces, and returns a short
+// reason for rejecting the line or "" to allow it. Rejections are
+// counted in stats as learn.rejected.<reason>.
+//
+// Filters may be called concurrently by LearnStream.
+type LearnFilter func(text string, tokens []string) string
+
+// MinTokens rejects lines with fewer than n tokens.
+func MinTokens(n int) LearnFilter {
+	return func(text string, tokens []string) string {
+		if len(tokens) < n {
+			return "too_short"
+		}
+		return ""
+	}
+}
+
+// MaxTokens rejects lines with more than n tokens, like pasted walls
+// of text.
+func MaxTokens(n int) LearnFilter {
+	return func(text string, tokens []string) string {
+		if len(tokens) > n {
+			return "too_long"
+		}
+		return ""
+	}
+}
+
+// MaxNonWordRatio rejects lines where more than ratio of the tokens
+// aren't words, like stack traces and line noise.
+func MaxNonWordRatio(ratio float64) LearnFilter {
+	return func(text string, tokens []string) string {
+		var nonWords int
+		for _, token := range tokens {
+			if classifyToken(token) != WordToken {
+				nonWords++
+			}
+		}
+
+		if len(tokens) > 0 && float64(nonWords)/float64(len(tokens)) > ratio {
+			return "non_words"
+		}
+		return ""
+	}
+}
+
+// Blocklist rejects lines matching any of patterns.
+func Blocklist(patterns ...*regexp.Regexp) LearnFilter {
+	return func(text string, tokens []string) string {
+		for _, re := range patterns {
+			if re.MatchString(text) {
+				return "blocklist"
+			}
+		}
+		return ""
+	}
+}
+
+// SetLearnFilters replaces the filters that Learn checks each line
+// against, in order. Duplicate suppression, enabled with SetDedupe,
+// runs after all of them.
+func (b *Cobe2Brain) SetLearnFilters(filters ...LearnFilter) {
+	b.filters = filters
+}
+
+// AddLearnFilter adds a filter to the end of the chain.
+func (b *Cobe2Brain) AddLearnFilter(f LearnFilter) {
+	b.filters = append(b.filters, f)
+}
+
+// SetDedupe makes Learn skip lines that exactly match one of the last
+// n lines learned. The hashes of those lines are kept in the brain, so
+// duplicates are caught across restarts.
+func (b *Cobe2Brain) SetDedupe(n int) error {
+	return b.graph.setDedupe(n)
+}
+
+// DelDedupe stops suppressing duplicates and forgets the hashes of
+// recently learned lines.
+func (b *Cobe2Brain) DelDedupe() error {
+	return b.graph.delDedupe()
+}
+
+// checkLearn runs the filters on a line, returning the reason it was
+// rejected or "".
+func (b *Cobe2Brain) checkLearn(text string, tokens []string) string {
+	var words []string
+	for _, token := range tokens {
+		if token != " " {
+			words = append(words, token)
+		}
+	}
+
+	for _, f := range b.filters {
+		if reason := f(text, words); reason != "" {
+			return reason
+		}
+	}
+
+	return ""
+}
+
+func rejectLearn(reason string) {
+	stats.Inc("learn.rejected", 1, 1.0)
+	stats.Inc("learn.rejected."+reason, 1, 1.0)
+}
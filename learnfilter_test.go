@@ -0,0 +1,144 @@
+package cobe
+
+import (
+	"os"
+	"regexp"
+	"testing"
+)
+
+func TestLearnFilters(t *testing.T) {
+	tok := newCobeTokenizer()
+
+	var tests = []struct {
+		filter   LearnFilter
+		text     string
+		expected string
+	}{
+		{MinTokens(3), "hello there", "too_short"},
+		{MinTokens(3), "hello there, you", ""},
+		{MaxTokens(4), "hello there, you", ""},
+		{MaxTokens(4), "hello there, how are you", "too_long"},
+		{MaxNonWordRatio(0.5), "at 12 + 34 = 46 !!", "non_words"},
+		{MaxNonWordRatio(0.5), "the cat sat on the mat.", ""},
+		{Blocklist(regexp.MustCompile(`(?i)buy now`)), "BUY NOW cheap pills", "blocklist"},
+		{Blocklist(regexp.MustCompile(`(?i)buy now`)), "I might buy it later", ""},
+	}
+
+	for ti, tt := range tests {
+		b := &Cobe2Brain{tok: tok, filters: []LearnFilter{tt.filter}}
+
+		reason := b.checkLearn(tt.text, tok.Split(tt.text))
+		if reason != tt.expected {
+			t.Errorf("[%d] %q: expected %q, got %q", ti, tt.text, tt.expected, reason)
+		}
+	}
+}
+
+func TestLearnFilterChain(t *testing.T) {
+	filename, err := tmpCopy("data/pg11.brain")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer os.Remove(filename)
+
+	b, err := OpenCobe2Brain(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var checked []string
+	b.SetLearnFilters(MaxTokens(5), func(text string, tokens []string) string {
+		checked = append(checked, text)
+		return ""
+	})
+
+	b.Learn("the zyzzyva crawled over the kumquat and then the mat")
+	b.Learn("the zyzzyva crawled over it")
+
+	if len(checked) != 1 || checked[0] != "the zyzzyva crawled over it" {
+		t.Errorf("Expected the custom filter to see only the short line, got %q", checked)
+	}
+
+	if _, err := b.graph.getTokenID("kumquat"); err == nil {
+		t.Error("Learned a line over the token limit")
+	}
+
+	if _, err := b.graph.getTokenID("zyzzyva"); err != nil {
+		t.Error("Didn't learn a line within the token limit")
+	}
+}
+
+func TestDedupe(t *testing.T) {
+	filename, err := tmpCopy("data/pg11.brain")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer os.Remove(filename)
+
+	b, err := OpenCobe2Brain(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = b.SetDedupe(3)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Changing the size replaces the dedupe statements.
+	old := b.graph.q.selectLineHash
+
+	err = b.SetDedupe(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := old.QueryRow(0).Scan(new(int)); err == nil {
+		t.Error("Old dedupe statement wasn't closed")
+	}
+
+	var tests = []struct {
+		text string
+		seen bool
+	}{
+		{"one line", false},
+		{"one line", true},
+		{"two lines", false},
+		{"one line", true},
+		{"three lines", false},
+		{"four lines", false},
+		// Only the last two lines are remembered.
+		{"one line", false},
+	}
+
+	for ti, tt := range tests {
+		if seen := b.graph.seenLine(tt.text); seen != tt.seen {
+			t.Errorf("[%d] %q: expected seen=%v, got %v", ti, tt.text, tt.seen, seen)
+		}
+	}
+
+	b.Close()
+
+	// The hashes and setting persist.
+	b, err = OpenCobe2Brain(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !b.graph.seenLine("one line") {
+		t.Error("Dedupe hashes weren't persisted")
+	}
+
+	err = b.DelDedupe()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if b.graph.seenLine("one line") {
+		t.Error("Dedupe still enabled after DelDedupe")
+	}
+
+	b.Close()
+}
@@ -0,0 +1,255 @@
+package cobe
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+type moderationKind int
+
+const (
+	// moderateWord matches tokens equal to the rule.
+	moderateWord moderationKind = iota
+
+	// moderateStem matches tokens with the same stem as the rule.
+	moderateStem
+
+	// moderateSubstring matches tokens containing the rule.
+	moderateSubstring
+)
+
+type moderationRule struct {
+	// text is the rule as written in the blocklist.
+	text     string
+	kind     moderationKind
+	skeleton string
+}
+
+// A Moderator keeps blocked words out of replies, and optionally out
+// of the brain entirely. Words are compared by their skeletons:
+// case-folded, without accents, and with look-alike letters and digits
+// replaced, so "Ηеll0" (with Greek and Cyrillic letters) matches
+// "hello".
+type Moderator struct {
+	// Mask replaces blocked words in replies with asterisks,
+	// rather than rejecting the replies that contain them.
+	Mask bool
+
+	rules []*moderationRule
+
+	lock   sync.Mutex
+	counts map[string]int
+}
+
+// NewModerator reads a blocklist with one rule per line. A rule is a
+// word to block, "stem:" and a word to block all the words sharing its
+// stem, or "substring:" and text to block any word containing it.
+// Blank lines and lines starting with # are ignored.
+func NewModerator(r io.Reader) (*Moderator, error) {
+	m := &Moderator{counts: make(map[string]int)}
+
+	s := bufio.NewScanner(r)
+	for n := 1; s.Scan(); n++ {
+		line := strings.TrimSpace(s.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		rule := &moderationRule{text: line, kind: moderateWord}
+
+		word := line
+		if strings.HasPrefix(line, "stem:") {
+			rule.kind = moderateStem
+			word = line[len("stem:"):]
+		} else if strings.HasPrefix(line, "substring:") {
+			rule.kind = moderateSubstring
+			word = line[len("substring:"):]
+		}
+
+		rule.skeleton = skeleton(strings.TrimSpace(word))
+		if rule.skeleton == "" {
+			return nil, fmt.Errorf("line %d: empty rule", n)
+		}
+
+		m.rules = append(m.rules, rule)
+	}
+
+	return m, s.Err()
+}
+
+// Counts returns the number of replies and lines each rule has
+// rejected or masked, by the rule's text in the blocklist.
+func (m *Moderator) Counts() map[string]int {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	ret := make(map[string]int, len(m.counts))
+	for rule, n := range m.counts {
+		ret[rule] = n
+	}
+
+	return ret
+}
+
+func (m *Moderator) count(rule *moderationRule) {
+	m.lock.Lock()
+	m.counts[rule.text]++
+	m.lock.Unlock()
+
+	stats.Inc("moderation.matched", 1, 1.0)
+}
+
+// match returns the rule that blocks word, or nil. Stem rules are
+// skipped if s is nil.
+func (m *Moderator) match(word string, s Stemmer) *moderationRule {
+	sk := skeleton(word)
+	if sk == "" {
+		return nil
+	}
+
+	var stem string
+	for _, rule := range m.rules {
+		switch rule.kind {
+		case moderateWord:
+			if sk == rule.skeleton {
+				return rule
+			}
+		case moderateSubstring:
+			if strings.Contains(sk, rule.skeleton) {
+				return rule
+			}
+		case moderateStem:
+			if s == nil {
+				continue
+			}
+
+			if stem == "" {
+				stem = s.Stem(sk)
+			}
+
+			if stem != "" && stem == s.Stem(rule.skeleton) {
+				return rule
+			}
+		}
+	}
+
+	return nil
+}
+
+// allowReply reports whether a reply candidate may be used. When
+// masking, every reply is allowed and blocked words are masked later.
+func (m *Moderator) allowReply(r *Reply, s Stemmer) bool {
+	if m.Mask {
+		return true
+	}
+
+	for _, word := range r.words() {
+		if rule := m.match(word, s); rule != nil {
+			m.count(rule)
+			stats.Inc("reply.candidate.moderated", 1, 1.0)
+			return false
+		}
+	}
+
+	return true
+}
+
+// mask returns the text of r with each blocked word replaced by
+// asterisks.
+func (m *Moderator) mask(r *Reply, s Stemmer) string {
+	return r.join(func(word string) string {
+		rule := m.match(word, s)
+		if rule == nil {
+			return word
+		}
+
+		m.count(rule)
+		return strings.Repeat("*", len([]rune(word)))
+	})
+}
+
+// moderatePivots drops the blocked tokens from tokenIds when m
+// rejects replies, since every reply built around one of them would
+// be rejected.
+func (b *Cobe2Brain) moderatePivots(tokenIds []tokenID, m *Moderator) []tokenID {
+	if m == nil || m.Mask {
+		return tokenIds
+	}
+
+	var ret []tokenID
+	for _, id := range tokenIds {
+		if m.match(b.graph.getTokenText(id), b.graph.stemmer) == nil {
+			ret = append(ret, id)
+		}
+	}
+
+	return ret
+}
+
+// ModerateReplies makes replies avoid words blocked by m, or mask them
+// if m.Mask is set. A nil m turns reply moderation off.
+func (b *Cobe2Brain) ModerateReplies(m *Moderator) {
+	b.moderator = m
+}
+
+// ModerateLearn adds a learn filter that rejects lines containing
+// words blocked by m, so they never become tokens in the brain.
+func (b *Cobe2Brain) ModerateLearn(m *Moderator) {
+	b.AddLearnFilter(func(text string, tokens []string) string {
+		for _, token := range tokens {
+			if rule := m.match(token, b.graph.stemmer); rule != nil {
+				m.count(rule)
+				return "moderation"
+			}
+		}
+
+		return ""
+	})
+}
+
+// confusables maps letters that look like Latin ones, and the digits
+// and symbols used for them, to the Latin letter.
+var confusables = map[rune]rune{
+	// Cyrillic
+	'а': 'a', 'в': 'b', 'е': 'e', 'ё': 'e', 'к': 'k', 'м': 'm',
+	'н': 'h', 'о': 'o', 'р': 'p', 'с': 'c', 'т': 't', 'у': 'y',
+	'х': 'x', 'і': 'i', 'ј': 'j', 'ѕ': 's', 'ԁ': 'd', 'ԛ': 'q',
+	'ԝ': 'w', 'ӏ': 'l',
+
+	// Greek
+	'α': 'a', 'β': 'b', 'ε': 'e', 'η': 'n', 'ι': 'i', 'κ': 'k',
+	'ν': 'v', 'ο': 'o', 'ρ': 'p', 'τ': 't', 'υ': 'u', 'χ': 'x',
+	'ω': 'w',
+
+	// Digits and symbols
+	'0': 'o', '1': 'i', '3': 'e', '4': 'a', '5': 's', '7': 't',
+	'@': 'a', '$': 's', '!': 'i', '|': 'l',
+}
+
+// skeleton reduces s to a form where look-alike spellings compare
+// equal.
+func skeleton(s string) string {
+	// NFKC turns fullwidth and other compatibility forms into
+	// plain ones.
+	s = stripAccents(foldCase(norm.NFKC.String(s)))
+
+	return strings.Map(func(r rune) rune {
+		if c, ok := confusables[r]; ok {
+			return c
+		}
+
+		// Zero-width characters and soft hyphens hide inside
+		// words.
+		if unicode.Is(unicode.Cf, r) {
+			return -1
+		}
+
+		return r
+	}, s)
+}
@@ -0,0 +1,165 @@
+package cobe
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"bitbucket.org/tebeka/snowball"
+)
+
+func TestSkeleton(t *testing.T) {
+	var tests = []struct {
+		word     string
+		expected string
+	}{
+		{"hello", "hello"},
+		{"HeLLo", "hello"},
+		{"héllo", "hello"},
+		{"h3ll0", "hello"},
+		{"hеllо", "hello"}, // Cyrillic е and о
+		{"ηεllο", "nello"}, // Greek
+		{"he\u200bllo", "hello"},
+		{"ｈｅｌｌｏ", "hello"},
+		{"$h!t", "shit"},
+	}
+
+	for ti, tt := range tests {
+		if sk := skeleton(tt.word); sk != tt.expected {
+			t.Errorf("[%d] %s\n%s !=\n%s", ti, tt.word, sk, tt.expected)
+		}
+	}
+}
+
+func TestModeratorMatch(t *testing.T) {
+	m, err := NewModerator(strings.NewReader(
+		"# comment\n\nfrob\nstem:jump\nsubstring:zork\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	snow, _ := snowball.New("english")
+	s := newCobeStemmer(snow)
+
+	var tests = []struct {
+		word     string
+		stemmer  Stemmer
+		expected string
+	}{
+		{"frob", s, "frob"},
+		{"FR0B", s, "frob"},
+		{"frobs", s, ""},
+		{"jumping", s, "stem:jump"},
+		{"jumping", nil, ""},
+		{"jump", nil, ""},
+		{"zorkmid", s, "substring:zork"},
+		{"z0rkmid", nil, "substring:zork"},
+		{"cork", s, ""},
+	}
+
+	for ti, tt := range tests {
+		var text string
+		if rule := m.match(tt.word, tt.stemmer); rule != nil {
+			text = rule.text
+		}
+
+		if text != tt.expected {
+			t.Errorf("[%d] %s\n%q !=\n%q", ti, tt.word, text, tt.expected)
+		}
+	}
+}
+
+func TestModeratorErrors(t *testing.T) {
+	_, err := NewModerator(strings.NewReader("frob\nstem:\n"))
+	if err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Errorf("Expected an error on line 2, got %v", err)
+	}
+}
+
+func TestModerateReply(t *testing.T) {
+	filename, err := tmpCopy("data/pg11.brain")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(filename)
+
+	b, err := OpenCobe2Brain(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m, err := NewModerator(strings.NewReader("stem:queen\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b.ModerateReplies(m)
+
+	reply := b.ReplyWithOptions("the King and Queen", DefaultReplyOptions)
+	if strings.Contains(strings.ToLower(reply), "queen") {
+		t.Errorf("Reply contains a blocked word: %s", reply)
+	}
+
+	m.Mask = true
+	reply = b.ReplyWithOptions("Queen", DefaultReplyOptions)
+	if strings.Contains(strings.ToLower(reply), "queen") || !strings.Contains(reply, "*****") {
+		t.Errorf("Expected a masked reply: %s", reply)
+	}
+
+	if m.Counts()["stem:queen"] == 0 {
+		t.Errorf("Expected matches for stem:queen, got %v", m.Counts())
+	}
+}
+
+// A brain where every reply has a blocked word gives up rather than
+// searching forever.
+func TestModerateEveryReply(t *testing.T) {
+	b, filename := newTestBrain(t)
+	defer os.Remove(filename)
+
+	m, err := NewModerator(strings.NewReader("darn\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b.Learn("the cat sat on the darn mat")
+	b.ModerateReplies(m)
+
+	reply := b.ReplyWithOptions("cat", ReplyOptions{Duration: 20 * time.Millisecond})
+	if reply != "I don't know enough to answer you yet!" {
+		t.Errorf("Expected no reply, got: %s", reply)
+	}
+}
+
+func TestModerateLearn(t *testing.T) {
+	filename, err := tmpCopy("data/pg11.brain")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(filename)
+
+	b, err := OpenCobe2Brain(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m, err := NewModerator(strings.NewReader("zyzzyva\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.ModerateLearn(m)
+
+	b.Learn("the ZYZZYVA crawled over the hedge")
+	b.Learn("the zyzzyva crawled over the hedge")
+
+	for _, token := range []string{"ZYZZYVA", "zyzzyva"} {
+		if _, err := b.graph.getTokenID(token); err == nil {
+			t.Errorf("Blocked token was learned: %s", token)
+		}
+	}
+
+	if m.Counts()["zyzzyva"] != 2 {
+		t.Errorf("Expected 2 rejections, got %v", m.Counts())
+	}
+}
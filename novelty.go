@@ -0,0 +1,22 @@
+package cobe
+
+// SetNovelty makes the brain fingerprint each sentence it learns from
+// now on, so with SetRequireNovel replies don't repeat one verbatim
+// or share a run of more than n tokens with it. Sentences learned
+// before novelty was enabled aren't fingerprinted.
+func (b *Cobe2Brain) SetNovelty(n int) error {
+	return b.graph.setNovelty(n)
+}
+
+// SetRequireNovel makes replies that repeat a learned sentence, or
+// too long a run of one, be rejected. It has no effect unless the
+// brain fingerprints learned sentences; see SetNovelty.
+func (b *Cobe2Brain) SetRequireNovel(require bool) {
+	b.requireNovel = require
+}
+
+// DelNovelty stops fingerprinting learned sentences and forgets the
+// fingerprints recorded so far.
+func (b *Cobe2Brain) DelNovelty() error {
+	return b.graph.delNovelty()
+}
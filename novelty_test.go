@@ -0,0 +1,140 @@
+package cobe
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+// learnedPath returns the node path that learning text would follow.
+func learnedPath(b *Cobe2Brain, text string) []nodeID {
+	var ids []tokenID
+	for _, token := range b.tok.Split(text) {
+		if token == " " {
+			ids = append(ids, spaceTokenID)
+		} else {
+			ids = append(ids, b.graph.getOrCreateToken(token))
+		}
+	}
+
+	var path []nodeID
+	for i, e := range b.chainEdges(ids) {
+		if i == 0 {
+			path = append(path, b.graph.getOrCreateNode(e.prev))
+		}
+		path = append(path, b.graph.getOrCreateNode(e.next))
+	}
+
+	return path
+}
+
+func TestNovelty(t *testing.T) {
+	b, filename := newTestBrain(t)
+	defer os.Remove(filename)
+
+	if err := b.SetNovelty(2); err == nil {
+		t.Error("Expected an error for a span shorter than the order")
+	}
+
+	learned := "the quick brown fox jumps over the lazy dog"
+
+	b.Learn(learned)
+	if b.graph.isCopy(learnedPath(b, learned)) {
+		t.Error("Sentence was fingerprinted before novelty was set")
+	}
+
+	err := b.SetNovelty(5)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Changing the span replaces the novelty statements.
+	old := b.graph.q.selectSpan
+
+	err = b.SetNovelty(4)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := old.QueryRow(0).Scan(new(int)); err == nil {
+		t.Error("Old novelty statement wasn't closed")
+	}
+
+	b.Learn(learned)
+
+	var tests = []struct {
+		text     string
+		expected bool
+	}{
+		{learned, true},
+		{"my quick brown fox jumps over happily", true},
+		{"my quick brown fox jumps happily", false},
+		{"the quick brown fox", false},
+		{"the lazy dog jumps over the quick brown fox", false},
+	}
+
+	for ti, tt := range tests {
+		if copied := b.graph.isCopy(learnedPath(b, tt.text)); copied != tt.expected {
+			t.Errorf("[%d] %s\n%v !=\n%v", ti, tt.text, copied, tt.expected)
+		}
+	}
+
+	err = b.DelNovelty()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if b.graph.isCopy(learnedPath(b, learned)) {
+		t.Error("Sentence was still fingerprinted after DelNovelty")
+	}
+}
+
+func TestRequireNovel(t *testing.T) {
+	b, filename := newTestBrain(t)
+	defer os.Remove(filename)
+
+	err := b.SetNovelty(5)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The only novel reply about the cat takes the dog's ending.
+	b.Learn("the cat sat on the mat")
+	b.Learn("the dog sat on the grass")
+
+	b.SetRequireNovel(true)
+	opts := ReplyOptions{Duration: 50 * time.Millisecond}
+
+	for i := 0; i < 5; i++ {
+		r := b.ReplyWithOptions("cat", opts)
+		if r != "the cat sat on the grass" {
+			t.Errorf("Expected a novel reply, got: %s", r)
+		}
+	}
+}
+
+// With only one sentence learned, every reply is a copy and there's
+// nothing novel to say.
+func TestRequireNovelNoReply(t *testing.T) {
+	b, filename := newTestBrain(t)
+	defer os.Remove(filename)
+
+	err := b.SetNovelty(4)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b.Learn("the cat sat on the mat")
+
+	b.SetRequireNovel(true)
+	opts := ReplyOptions{Duration: 20 * time.Millisecond}
+
+	if reply := b.BestReply("cat", opts); reply != nil {
+		t.Errorf("Expected no reply, got: %s", reply)
+	}
+
+	r := b.ReplyWithOptions("cat", opts)
+	if r != "I don't know enough to answer you yet!" {
+		t.Errorf("Expected no reply, got: %s", r)
+	}
+}
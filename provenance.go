@@ -0,0 +1,25 @@
+package cobe
+
+// SetProvenance makes the brain record, for each line learned from
+// now on with a LearnRecord naming its Author, who taught it, where,
+// and which edges it incremented. ForgetAuthor uses this to remove
+// what someone taught. Lines without an author aren't recorded.
+func (b *Cobe2Brain) SetProvenance() error {
+	return b.graph.setProvenance()
+}
+
+// DelProvenance stops recording who taught each line and forgets the
+// records kept so far. What they taught stays in the brain.
+func (b *Cobe2Brain) DelProvenance() error {
+	return b.graph.delProvenance()
+}
+
+// ForgetAuthor removes everything author taught the brain since
+// provenance was enabled: edge counts are decremented, and edges,
+// nodes and tokens nobody else taught are deleted. The dedupe and
+// novelty fingerprints of their lines are removed too, unless another
+// author's line shares them. It returns the number of lines
+// forgotten, and fails during a batch.
+func (b *Cobe2Brain) ForgetAuthor(author string) (int, error) {
+	return b.graph.forgetAuthor(author)
+}
@@ -0,0 +1,150 @@
+package cobe
+
+import (
+	"os"
+	"testing"
+)
+
+func nodeCountTotal(t *testing.T, b *Cobe2Brain) int64 {
+	var total int64
+	err := b.graph.db.QueryRow("SELECT sum(count) FROM nodes").Scan(&total)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return total
+}
+
+func TestForgetAuthor(t *testing.T) {
+	var (
+		alice = []LearnRecord{
+			{Text: "the zebra sat on the mat", Author: "alice"},
+			{Text: "the cat sat on the mat", Author: "alice"},
+			{Text: "the cat sat on the mat", Author: "alice"},
+		}
+		others = []LearnRecord{
+			{Text: "the dog sat on the mat", Author: "bob"},
+			{Text: "the cat sat on the rug", Channel: "#cobe"},
+		}
+	)
+
+	b, filename := newTestBrain(t)
+	defer os.Remove(filename)
+
+	if _, err := b.ForgetAuthor("alice"); err == nil {
+		t.Error("Expected an error without provenance")
+	}
+
+	err := b.SetProvenance()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Enabling provenance again replaces its statements.
+	old := b.graph.q.insertLine
+
+	err = b.SetProvenance()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := old.Exec("nobody", "", 0, ""); err == nil {
+		t.Error("Old provenance statement wasn't closed")
+	}
+
+	for _, rec := range append(alice, others...) {
+		b.LearnRecord(rec)
+	}
+
+	n, err := b.ForgetAuthor("alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if n != len(alice) {
+		t.Errorf("Expected %d lines forgotten, got %d", len(alice), n)
+	}
+
+	if _, err := b.graph.getTokenID("zebra"); err == nil {
+		t.Error("Token only alice taught wasn't deleted")
+	}
+
+	if _, err := b.graph.getTokenID("cat"); err != nil {
+		t.Error("Token others taught was deleted")
+	}
+
+	// The brain should be as if alice had never said anything.
+	expected, expectedFilename := newTestBrain(t)
+	defer os.Remove(expectedFilename)
+
+	for _, rec := range others {
+		expected.LearnRecord(rec)
+	}
+
+	tokens, nodes, edges := edgeTotals(t, b)
+	eTokens, eNodes, eEdges := edgeTotals(t, expected)
+	if tokens != eTokens || nodes != eNodes || edges != eEdges {
+		t.Errorf("Expected %d tokens, %d nodes, %d edges; got %d, %d, %d",
+			eTokens, eNodes, eEdges, tokens, nodes, edges)
+	}
+
+	if total, eTotal := nodeCountTotal(t, b), nodeCountTotal(t, expected); total != eTotal {
+		t.Errorf("Expected node counts to total %d, got %d", eTotal, total)
+	}
+
+	n, err = b.ForgetAuthor("alice")
+	if err != nil || n != 0 {
+		t.Errorf("Expected nothing left to forget, got %d, %v", n, err)
+	}
+}
+
+func TestForgetAuthorFingerprints(t *testing.T) {
+	b, filename := newTestBrain(t)
+	defer os.Remove(filename)
+
+	// A long novelty span fingerprints only whole sentences.
+	for _, err := range []error{b.SetProvenance(), b.SetDedupe(10), b.SetNovelty(10)} {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Bob's lines keep every node of alice's, so her sentence
+	// follows the same path after she's forgotten.
+	alice := "the cat sat on the mat"
+	bob := []string{"the cat sat on the rug", "a dog sat on the mat"}
+
+	b.LearnRecord(LearnRecord{Text: alice, Author: "alice"})
+	for _, text := range bob {
+		b.LearnRecord(LearnRecord{Text: text, Author: "bob"})
+	}
+
+	if !b.graph.isCopy(learnedPath(b, alice)) {
+		t.Fatal("Alice's sentence wasn't fingerprinted")
+	}
+
+	_, err := b.ForgetAuthor("alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if b.graph.isCopy(learnedPath(b, alice)) {
+		t.Error("Alice's sentence is still fingerprinted for novelty")
+	}
+
+	for _, text := range bob {
+		if !b.graph.isCopy(learnedPath(b, text)) {
+			t.Errorf("Bob's sentence lost its novelty fingerprint: %s", text)
+		}
+	}
+
+	if b.graph.seenLine(alice) {
+		t.Error("Alice's line is still remembered by dedupe")
+	}
+
+	for _, text := range bob {
+		if !b.graph.seenLine(text) {
+			t.Errorf("Bob's line was forgotten by dedupe: %s", text)
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package cobe
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+)
+
+// A LearnRecord is text to learn along with what's known about where
+// it came from. Only Text is required.
+type LearnRecord struct {
+	Text    string
+	Author  string
+	Channel string
+
+	// Timestamp is when the text was written, if known.
+	Timestamp time.Time
+
+	// Weight is how much the record should count for. Zero means
+	// the default weight of one.
+	Weight float64
+
+	// Extra holds any other fields from the record's source, so
+	// they're carried along rather than lost.
+	Extra map[string]json.RawMessage
+}
+
+// UnmarshalJSON reads a record from an object like
+//
+//	{"text": "hello", "author": "alice", "channel": "#cobe",
+//	 "timestamp": "2014-03-01T12:00:00Z", "weight": 2}
+//
+// The timestamp may also be a number of seconds since the Unix epoch.
+// Unrecognized fields are kept in Extra.
+func (r *LearnRecord) UnmarshalJSON(data []byte) error {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+
+	var rec LearnRecord
+
+	text, ok := fields["text"]
+	if !ok {
+		return errors.New("missing text")
+	}
+
+	if err := json.Unmarshal(text, &rec.Text); err != nil {
+		return fmt.Errorf("text: %s", err)
+	}
+
+	if v, ok := fields["author"]; ok {
+		if err := json.Unmarshal(v, &rec.Author); err != nil {
+			return fmt.Errorf("author: %s", err)
+		}
+	}
+
+	if v, ok := fields["channel"]; ok {
+		if err := json.Unmarshal(v, &rec.Channel); err != nil {
+			return fmt.Errorf("channel: %s", err)
+		}
+	}
+
+	if v, ok := fields["timestamp"]; ok {
+		ts, err := parseTimestamp(v)
+		if err != nil {
+			return fmt.Errorf("timestamp: %s", err)
+		}
+		rec.Timestamp = ts
+	}
+
+	if v, ok := fields["weight"]; ok {
+		if err := json.Unmarshal(v, &rec.Weight); err != nil {
+			return fmt.Errorf("weight: %s", err)
+		}
+
+		if rec.Weight < 0 {
+			return fmt.Errorf("weight: %g is negative", rec.Weight)
+		}
+	}
+
+	for _, key := range []string{"text", "author", "channel", "timestamp", "weight"} {
+		delete(fields, key)
+	}
+
+	if len(fields) > 0 {
+		rec.Extra = fields
+	}
+
+	*r = rec
+	return nil
+}
+
+func parseTimestamp(data []byte) (time.Time, error) {
+	var secs float64
+	if err := json.Unmarshal(data, &secs); err == nil {
+		whole, frac := math.Modf(secs)
+		return time.Unix(int64(whole), int64(frac*1e9)).UTC(), nil
+	}
+
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return time.Time{}, errors.New("expected a string or number")
+	}
+
+	return time.Parse(time.RFC3339, s)
+}
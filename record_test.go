@@ -0,0 +1,64 @@
+package cobe
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestLearnRecordJSON(t *testing.T) {
+	var tests = []struct {
+		json     string
+		expected LearnRecord
+		extra    []string
+		err      bool
+	}{
+		{`{"text": "hello"}`, LearnRecord{Text: "hello"}, nil, false},
+		{`{"text": "hi", "author": "alice", "channel": "#cobe",
+		   "timestamp": "2014-03-01T12:00:00Z", "weight": 2.5}`,
+			LearnRecord{Text: "hi", Author: "alice", Channel: "#cobe",
+				Timestamp: time.Date(2014, 3, 1, 12, 0, 0, 0, time.UTC), Weight: 2.5},
+			nil, false},
+		{`{"text": "hi", "timestamp": 1393675200.5, "id": 7, "tags": ["a"]}`,
+			LearnRecord{Text: "hi",
+				Timestamp: time.Date(2014, 3, 1, 12, 0, 0, 5e8, time.UTC)},
+			[]string{"id", "tags"}, false},
+		{`{"author": "alice"}`, LearnRecord{}, nil, true},
+		{`{"text": 5}`, LearnRecord{}, nil, true},
+		{`{"text": "hi", "timestamp": "yesterday"}`, LearnRecord{}, nil, true},
+		{`{"text": "hi", "weight": -1}`, LearnRecord{}, nil, true},
+		{`["text"]`, LearnRecord{}, nil, true},
+	}
+
+	for ti, tt := range tests {
+		var rec LearnRecord
+		err := json.Unmarshal([]byte(tt.json), &rec)
+		if tt.err {
+			if err == nil {
+				t.Errorf("[%d] expected error, got %+v", ti, rec)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("[%d] %s", ti, err)
+			continue
+		}
+
+		if rec.Text != tt.expected.Text || rec.Author != tt.expected.Author ||
+			rec.Channel != tt.expected.Channel || rec.Weight != tt.expected.Weight ||
+			!rec.Timestamp.Equal(tt.expected.Timestamp) {
+			t.Errorf("[%d] expected %+v, got %+v", ti, tt.expected, rec)
+		}
+
+		if len(rec.Extra) != len(tt.extra) {
+			t.Errorf("[%d] expected extra %v, got %v", ti, tt.extra, rec.Extra)
+		}
+
+		for _, key := range tt.extra {
+			if _, ok := rec.Extra[key]; !ok {
+				t.Errorf("[%d] missing extra %s", ti, key)
+			}
+		}
+	}
+}
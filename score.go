@@ -20,17 +20,24 @@ func (s *cobeScorer) Score(reply *Reply) float64 {
 
 	// Apply MegaHAL's fudge factor to discourage overly long
 	// replies.
+	return info / lengthPenalty(replyWords(reply))
+}
 
-	// First, we have (graph.order - 1) extra edges on either end
-	// of the reply, cobe 2.0 learns from (endToken, endToken,
-	// ...).
-	nWords := len(reply.nodes) - (g.order-1)*2
+// replyWords counts the words a reply is penalized for.
+func replyWords(reply *Reply) int {
+	// We have (graph.order - 1) extra edges on either end of the
+	// reply, cobe 2.0 learns from (endToken, endToken, ...).
+	return len(reply.nodes) - (reply.graph.order-1)*2
+}
 
+// lengthPenalty returns what the information content of a reply of
+// nWords is divided by.
+func lengthPenalty(nWords int) float64 {
 	if nWords > 16 {
-		info /= math.Sqrt(float64(nWords - 1))
+		return math.Sqrt(float64(nWords - 1))
 	} else if nWords >= 32 {
-		info /= float64(nWords)
+		return float64(nWords)
 	}
 
-	return info
+	return 1
 }
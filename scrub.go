@@ -0,0 +1,259 @@
+package cobe
+
+import (
+	"bytes"
+	"math"
+	"net"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// ScrubMode says what Learn does with personal data like email
+// addresses, phone numbers, IP addresses, credit card numbers and API
+// keys, so Reply can't repeat them later.
+type ScrubMode int
+
+const (
+	scrubUnset ScrubMode = iota
+
+	// ScrubOff learns text as it is. It's the default for a brain.
+	ScrubOff
+
+	// ScrubReplace replaces each piece of personal data with a
+	// placeholder token, like scrubbed:email.
+	ScrubReplace
+
+	// ScrubDrop doesn't learn lines with personal data at all.
+	ScrubDrop
+)
+
+var scrubModeNames = map[string]ScrubMode{
+	"off":     ScrubOff,
+	"replace": ScrubReplace,
+	"drop":    ScrubDrop,
+}
+
+// ParseScrubMode returns the mode named off, replace or drop.
+func ParseScrubMode(name string) (ScrubMode, bool) {
+	mode, ok := scrubModeNames[strings.ToLower(name)]
+	return mode, ok
+}
+
+// SetScrub sets what Learn does with personal data.
+func (b *Cobe2Brain) SetScrub(mode ScrubMode) {
+	b.scrub = mode
+}
+
+const placeholderPrefix = "scrubbed:"
+
+func isPlaceholder(token string) bool {
+	if !strings.HasPrefix(token, placeholderPrefix) {
+		return false
+	}
+
+	_, ok := scrubKinds[token[len(placeholderPrefix):]]
+	return ok
+}
+
+// A scrubPattern finds candidates for one kind of personal data. If
+// check isn't nil, only candidates it accepts are scrubbed. Patterns
+// that would mangle ordinary links skip URL tokens unless urls is set.
+type scrubPattern struct {
+	kind  string
+	re    *regexp.Regexp
+	check func(string) bool
+	urls  bool
+}
+
+// Scrub patterns, in the order they're applied. Earlier kinds are
+// replaced before later ones can match parts of them.
+var scrubPatterns = []scrubPattern{
+	{"email", regexp.MustCompile(`[\w.+-]+@[\w-]+(\.[\w-]+)*\.[A-Za-z]{2,}`), nil, true},
+
+	// Well known API key formats: AWS, GitHub, Slack, Stripe and
+	// OpenAI style secret keys.
+	{"key", regexp.MustCompile(`\b(AKIA[0-9A-Z]{16}|gh[pousr]_[A-Za-z0-9]{36,}|` +
+		`xox[abprs]-[A-Za-z0-9-]{10,}|[sr]k_(live|test)_[A-Za-z0-9]{16,}|sk-[A-Za-z0-9_-]{20,})\b`), nil, true},
+
+	{"card", regexp.MustCompile(`\b\d(?:[ -]?\d){12,18}\b`), isCardNumber, false},
+	{"ip", regexp.MustCompile(`\b\d{1,3}(\.\d{1,3}){3}\b`), isIP, true},
+	{"ip", regexp.MustCompile(`(\b|::)[0-9A-Fa-f]{0,4}(:[0-9A-Fa-f]{0,4}){2,7}(\b|::)`), isIPv6, true},
+
+	// A trailing :digit is matched so isPhone can turn down times.
+	{"phone", regexp.MustCompile(`(\+\d{1,3}[ .-]?)?(\(\d{1,4}\)[ .-]?)?\d{2,4}([ .-]?\d{2,4}){1,4}\b(:\d)?`), isPhone, false},
+
+	// Anything else long and random looking is probably a secret.
+	{"key", regexp.MustCompile(`[A-Za-z0-9_+/=-]{20,}`), isHighEntropy, false},
+}
+
+var scrubKinds = map[string]bool{
+	"email": true, "key": true, "card": true, "ip": true, "phone": true,
+}
+
+// scrubText replaces personal data in text with placeholders. It
+// returns the new text and the kind of each replacement.
+func scrubText(text string) (string, []string) {
+	var kinds []string
+
+	for _, p := range scrubPatterns {
+		replace := func(s string) string {
+			return p.re.ReplaceAllStringFunc(s, func(m string) string {
+				if p.check != nil && !p.check(m) {
+					return m
+				}
+
+				kinds = append(kinds, p.kind)
+				return placeholderPrefix + p.kind
+			})
+		}
+
+		if p.urls {
+			text = replace(text)
+		} else {
+			text = replaceOutsideURLs(text, replace)
+		}
+	}
+
+	return text, kinds
+}
+
+var fieldRegexp = regexp.MustCompile(`\S+`)
+
+// replaceOutsideURLs applies replace to the parts of text between
+// its URL tokens, leaving the URLs as they are.
+func replaceOutsideURLs(text string, replace func(string) string) string {
+	var buf bytes.Buffer
+
+	start := 0
+	for _, loc := range fieldRegexp.FindAllStringIndex(text, -1) {
+		if classifyToken(text[loc[0]:loc[1]]) != URLToken {
+			continue
+		}
+
+		buf.WriteString(replace(text[start:loc[0]]))
+		buf.WriteString(text[loc[0]:loc[1]])
+		start = loc[1]
+	}
+
+	buf.WriteString(replace(text[start:]))
+
+	return buf.String()
+}
+
+// scrubLearn applies the brain's ScrubMode to text, returning the
+// text to learn and false if it shouldn't be learned at all.
+func (b *Cobe2Brain) scrubLearn(text string) (string, bool) {
+	if b.scrub == scrubUnset || b.scrub == ScrubOff {
+		return text, true
+	}
+
+	scrubbed, kinds := scrubText(text)
+	if len(kinds) == 0 {
+		return text, true
+	}
+
+	for _, kind := range kinds {
+		stats.Inc("learn.scrubbed."+kind, 1, 1.0)
+	}
+
+	if b.scrub == ScrubDrop {
+		rejectLearn("pii")
+		return "", false
+	}
+
+	return scrubbed, true
+}
+
+// isCardNumber checks the length and Luhn checksum of a possible
+// credit card number.
+func isCardNumber(s string) bool {
+	var digits []int
+	for _, r := range s {
+		if r >= '0' && r <= '9' {
+			digits = append(digits, int(r-'0'))
+		}
+	}
+
+	if len(digits) < 13 || len(digits) > 19 {
+		return false
+	}
+
+	var sum int
+	for i := range digits {
+		d := digits[len(digits)-1-i]
+		if i%2 == 1 {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+	}
+
+	return sum%10 == 0
+}
+
+func isIP(s string) bool {
+	return net.ParseIP(s) != nil
+}
+
+// isIPv6 accepts IPv6 addresses with at least a few digits, so "::"
+// on its own isn't scrubbed.
+func isIPv6(s string) bool {
+	ip := net.ParseIP(s)
+	return ip != nil && ip.To4() == nil && len(strings.Trim(s, ":")) >= 3
+}
+
+var isoDateRegexp = regexp.MustCompile(`\b\d{4}-\d\d-\d\d\b`)
+var timeSuffixRegexp = regexp.MustCompile(`:\d$`)
+
+// isPhone accepts candidates with 9 to 15 digits that are grouped
+// like a phone number, rather than one long run of digits. Dates and
+// times, like 2024-01-15 12:30, aren't phone numbers.
+func isPhone(s string) bool {
+	if isoDateRegexp.MatchString(s) || timeSuffixRegexp.MatchString(s) {
+		return false
+	}
+
+	var digits int
+	for _, r := range s {
+		if r >= '0' && r <= '9' {
+			digits++
+		}
+	}
+
+	if digits < 9 || digits > 15 {
+		return false
+	}
+
+	return strings.HasPrefix(s, "+") || strings.ContainsAny(s, " .-()")
+}
+
+// isHighEntropy reports whether s mixes letters and digits with the
+// character variety of a random key, rather than a long word or path.
+func isHighEntropy(s string) bool {
+	if strings.HasPrefix(s, placeholderPrefix) {
+		return false
+	}
+
+	var hasLetter, hasDigit bool
+	counts := make(map[rune]int)
+	for _, r := range s {
+		counts[r]++
+		hasLetter = hasLetter || unicode.IsLetter(r)
+		hasDigit = hasDigit || unicode.IsDigit(r)
+	}
+
+	if !hasLetter || !hasDigit {
+		return false
+	}
+
+	var entropy float64
+	for _, n := range counts {
+		p := float64(n) / float64(len(s))
+		entropy -= p * math.Log2(p)
+	}
+
+	return entropy >= 3.5
+}
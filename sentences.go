@@ -0,0 +1,136 @@
+package cobe
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Abbreviations that are usually followed by a capitalized word
+// without ending the sentence. Compared lowercase, without the final
+// period.
+var abbreviations = map[string]bool{
+	"mr": true, "mrs": true, "ms": true, "dr": true, "prof": true,
+	"sr": true, "jr": true, "mt": true, "vs": true,
+	"col": true, "capt": true, "lt": true, "sgt": true,
+	"rev": true, "hon": true, "gov": true, "sen": true, "rep": true,
+	"vol": true, "fig": true, "approx": true, "dept": true,
+	"e.g": true, "i.e": true, "cf": true, "viz": true,
+	"inc": true, "ltd": true, "corp": true,
+}
+
+// Abbreviations that are also everyday words, so they only count
+// when capitalized, as in "St. Louis" or "Gen. Grant".
+var titleAbbreviations = map[string]bool{
+	"St": true, "Gen": true, "Co": true,
+}
+
+// Abbreviations that only count when a number follows, as in
+// "No. 5". Compared lowercase.
+var numberAbbreviations = map[string]bool{
+	"no": true,
+}
+
+// SplitSentences splits a paragraph into sentences. A sentence ends
+// with ., ! or ? (possibly followed by closing quotes or brackets),
+// and the next one starts with a capital letter, digit or opening
+// quote after whitespace. Periods in abbreviations, initials and
+// decimals don't end sentences.
+func SplitSentences(text string) []string {
+	var ret []string
+
+	start := 0
+	for i := 0; i < len(text); {
+		r, size := utf8.DecodeRuneInString(text[i:])
+		if !isTerminal(r) {
+			i += size
+			continue
+		}
+
+		// Consume the rest of the terminal punctuation, e.g. "?!"
+		// or "...", and any closing quotes.
+		end := i + size
+		for end < len(text) {
+			r2, size2 := utf8.DecodeRuneInString(text[end:])
+			if !isTerminal(r2) && !isCloser(r2) {
+				break
+			}
+			end += size2
+		}
+
+		if isBoundary(text, start, i, end) {
+			if s := strings.TrimSpace(text[start:end]); s != "" {
+				ret = append(ret, s)
+			}
+			start = end
+		}
+
+		i = end
+	}
+
+	if s := strings.TrimSpace(text[start:]); s != "" {
+		ret = append(ret, s)
+	}
+
+	return ret
+}
+
+// isBoundary reports whether the terminal punctuation at
+// text[term:end] ends the sentence that began at start.
+func isBoundary(text string, start, term, end int) bool {
+	// The next sentence must follow whitespace.
+	rest := text[end:]
+	trimmed := strings.TrimLeftFunc(rest, unicode.IsSpace)
+	if trimmed == "" || len(trimmed) == len(rest) {
+		return false
+	}
+
+	next, _ := utf8.DecodeRuneInString(trimmed)
+	if !unicode.IsUpper(next) && !unicode.IsDigit(next) && !isOpener(next) {
+		return false
+	}
+
+	// A single period may belong to an abbreviation or initial.
+	if strings.TrimRightFunc(text[term:end], isCloser) == "." {
+		word := lastWord(text[start:term])
+		if abbreviations[strings.ToLower(word)] || titleAbbreviations[word] {
+			return false
+		}
+
+		if numberAbbreviations[strings.ToLower(word)] && unicode.IsDigit(next) {
+			return false
+		}
+
+		if utf8.RuneCountInString(word) == 1 {
+			r, _ := utf8.DecodeRuneInString(word)
+			if unicode.IsUpper(r) {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+// lastWord returns the run of letters, digits and inner periods at
+// the end of s, so "said Dr" gives "Dr", "see e.g" gives "e.g" and
+// "came 1st" gives "1st".
+func lastWord(s string) string {
+	i := strings.LastIndexFunc(s, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '.'
+	})
+
+	return strings.Trim(s[i+1:], ".")
+}
+
+func isTerminal(r rune) bool {
+	return r == '.' || r == '!' || r == '?' || r == '…'
+}
+
+func isCloser(r rune) bool {
+	return strings.ContainsRune("\"')]”’»", r)
+}
+
+func isOpener(r rune) bool {
+	return strings.ContainsRune("\"'([“‘«¿¡", r)
+}
@@ -0,0 +1,41 @@
+package cobe
+
+import "testing"
+
+func TestSplitSentences(t *testing.T) {
+	var tests = []struct {
+		text     string
+		expected []string
+	}{
+		{"", []string{}},
+		{"Hello.", []string{"Hello."}},
+		{"Hello there. How are you?", []string{"Hello there.", "How are you?"}},
+		{"Really?! Yes.", []string{"Really?!", "Yes."}},
+		{"It costs 3.50 today. Cheap.", []string{"It costs 3.50 today.", "Cheap."}},
+		{"Ask Dr. Smith about it. He knows.", []string{"Ask Dr. Smith about it.", "He knows."}},
+		{"Fruit, e.g. Apples, are good.", []string{"Fruit, e.g. Apples, are good."}},
+		{"Written by J. R. R. Tolkien. Long.", []string{"Written by J. R. R. Tolkien.", "Long."}},
+		{"Wait... What? No.", []string{"Wait...", "What?", "No."}},
+		{"Well... maybe not.", []string{"Well... maybe not."}},
+		{`He said "Stop." Then he left.`, []string{`He said "Stop."`, "Then he left."}},
+		{`"Where?" she asked.`, []string{`"Where?" she asked.`}},
+		{"See the site.Com is down.", []string{"See the site.Com is down."}},
+		{"No ending punctuation", []string{"No ending punctuation"}},
+		{"¿Qué pasa? ¡Nada!", []string{"¿Qué pasa?", "¡Nada!"}},
+		{"I said no. Then he left.", []string{"I said no.", "Then he left."}},
+		{"No. I'm busy.", []string{"No.", "I'm busy."}},
+		{"Try No. 5 first.", []string{"Try No. 5 first."}},
+		{"He came 1st. Then he left.", []string{"He came 1st.", "Then he left."}},
+		{"Meet at St. Paul's. Soon.", []string{"Meet at St. Paul's.", "Soon."}},
+		{"Ask Gen. Grant. He knows.", []string{"Ask Gen. Grant.", "He knows."}},
+		{"Wait for the next gen. It will be better.", []string{"Wait for the next gen.", "It will be better."}},
+		{"We formed a co. It failed.", []string{"We formed a co.", "It failed."}},
+	}
+
+	for ti, tt := range tests {
+		sentences := SplitSentences(tt.text)
+		if !eq(sentences, tt.expected) {
+			t.Errorf("[%d] %s\n%q !=\n%q", ti, tt.text, sentences, tt.expected)
+		}
+	}
+}
@@ -1,28 +1,76 @@
 package cobe
 
 import (
+	"fmt"
 	"regexp"
 	"strings"
+	"sync"
 	"unicode"
 
 	"bitbucket.org/tebeka/snowball"
+	"golang.org/x/text/cases"
 	"golang.org/x/text/transform"
 	"golang.org/x/text/unicode/norm"
 )
 
-type stemmer interface {
-	Stem(word string) string
+// Stemmer maps tokens to stems. Tokens with the same stem are treated
+// as equivalent when picking reply pivots. An empty stem means the
+// token isn't stemmed.
+type Stemmer interface {
+	Stem(token string) string
 }
 
-// Wrap a snowball stemmer in one that also stems smileys.
+var (
+	stemmersLock sync.RWMutex
+	stemmers     = make(map[string]func() (Stemmer, error))
+)
+
+// RegisterStemmer makes a stemmer available by name, for use with
+// SetStemmer. The name is stored in the brain, so the same stemmer
+// must be registered whenever the brain is opened.
+//
+// Names that aren't registered are looked up as snowball languages.
+func RegisterStemmer(name string, f func() (Stemmer, error)) {
+	stemmersLock.Lock()
+	defer stemmersLock.Unlock()
+
+	if f == nil {
+		panic("cobe: RegisterStemmer func is nil")
+	}
+
+	if _, dup := stemmers[name]; dup {
+		panic("cobe: RegisterStemmer called twice for " + name)
+	}
+
+	stemmers[name] = f
+}
+
+func newStemmer(name string) (Stemmer, error) {
+	stemmersLock.RLock()
+	f, ok := stemmers[name]
+	stemmersLock.RUnlock()
+
+	if ok {
+		return f()
+	}
+
+	snow, err := snowball.New(name)
+	if err != nil {
+		return nil, fmt.Errorf("unknown stemmer: %s", name)
+	}
+
+	return newCobeStemmer(snow), nil
+}
+
+// Wrap a snowball stemmer in one that also stems emoticons and emoji.
 type cobeStemmer struct {
-	sub    stemmer
+	sub    Stemmer
 	words  *regexp.Regexp
 	smiley *regexp.Regexp
 	frowny *regexp.Regexp
 }
 
-func newCobeStemmer(s *snowball.Stemmer) *cobeStemmer {
+func newCobeStemmer(s Stemmer) *cobeStemmer {
 	cs := cobeStemmer{sub: s}
 	cs.words = regexp.MustCompile(`\w`)
 	cs.smiley = regexp.MustCompile(`:-?[ \)]*\)|☺|☺️`)
@@ -32,6 +80,12 @@ func newCobeStemmer(s *snowball.Stemmer) *cobeStemmer {
 }
 
 func (s *cobeStemmer) Stem(token string) string {
+	// Known emoticons come first, since some of them (xD, -1)
+	// look like words.
+	if stem, ok := emoticonStem(token); ok {
+		return stem
+	}
+
 	// Tokens with a word character go through the snowball stemmer.
 	if s.words.FindString(token) != "" {
 		return s.sub.Stem(stripAccents(strings.ToLower(token)))
@@ -45,7 +99,7 @@ func (s *cobeStemmer) Stem(token string) string {
 		return ":("
 	}
 
-	return ""
+	return emojiStem(token)
 }
 
 // stripAccents attempts to replace accented characters with an ASCII
@@ -61,6 +115,13 @@ func stripAccents(s string) string {
 	return s2
 }
 
+// foldCase returns the Unicode case folding of s, so "Alice", "alice"
+// and "ALICE" all map to the same string.
+func foldCase(s string) string {
+	// A Caser keeps state between calls, so don't share one.
+	return cases.Fold().String(s)
+}
+
 var stripT transform.Transformer
 
 func init() {
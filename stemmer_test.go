@@ -1,7 +1,12 @@
 package cobe
 
-import "testing"
-import "bitbucket.org/tebeka/snowball"
+import (
+	"os"
+	"sync"
+	"testing"
+
+	"bitbucket.org/tebeka/snowball"
+)
 
 func TestCobeStemmer(t *testing.T) {
 	snow, _ := snowball.New("english")
@@ -30,6 +35,22 @@ func TestCobeStemmer(t *testing.T) {
 		{":-(", ":("},
 		{":    (", ":("},
 		{":'    (", ":("},
+
+		{"xD", ":D"},
+		{"😂", ":D"},
+		{"😂😂😂", ":D"},
+		{"!!! 🤣", ":D"},
+		{"❤️", "<3"},
+		{"❤", "<3"},
+		{"😍", "<3"},
+		{">:(", ">:("},
+		{"😡", ">:("},
+		{"😮", ":O"},
+		{"👍🏽", "+1"},
+		{"-1", "-1"},
+		{"😦", ":("},
+		{"☺️", ":)"},
+		{"...", ""},
 	}
 
 	for ti, tt := range tests {
@@ -62,3 +83,69 @@ func TestStripAccents(t *testing.T) {
 		}
 	}
 }
+
+type prefixStemmer struct{}
+
+func (s prefixStemmer) Stem(token string) string {
+	if len(token) < 4 {
+		return ""
+	}
+
+	return token[:4]
+}
+
+// registerPrefix registers prefixStemmer once per process, since
+// RegisterStemmer panics on a duplicate name and -count reruns tests.
+var registerPrefix sync.Once
+
+func TestRegisterStemmer(t *testing.T) {
+	registerPrefix.Do(func() {
+		RegisterStemmer("test-prefix", func() (Stemmer, error) {
+			return prefixStemmer{}, nil
+		})
+	})
+
+	filename, err := tmpCopy("data/pg11.brain")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer os.Remove(filename)
+
+	g, err := openGraph(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = g.setStemmer("test-prefix")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rabbit, err := g.getTokenID("rabbit")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if ids := g.getTokensByStem("rabbits"); !containsId(ids, rabbit) {
+		t.Errorf("Expected rabbits to stem like rabbit, got %v", ids)
+	}
+
+	err = g.setStemmer("no-such-stemmer")
+	if err == nil {
+		t.Error("Expected an error setting an unknown stemmer")
+	}
+
+	g.close()
+
+	// Reopening the brain uses the registered stemmer.
+	g, err = openGraph(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer g.close()
+
+	if _, ok := g.stemmer.(prefixStemmer); !ok {
+		t.Errorf("Expected a prefixStemmer, got %T", g.stemmer)
+	}
+}
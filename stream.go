@@ -0,0 +1,349 @@
+package cobe
+
+import (
+	"context"
+	"runtime"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// A learnJob is a line of text on its way into the brain. It's
+// prepared without touching the database, so preparation can run in
+// parallel with writing.
+type learnJob struct {
+	rec     *LearnRecord
+	text    string
+	tokens  []string
+	classes []TokenClass
+	lang    string
+
+	// ids holds the token id of each of tokens, or 0 for those
+	// that haven't been resolved yet.
+	ids []tokenID
+
+	// edges is the chain of edges to learn, once ids are known.
+	edges []edge
+}
+
+// prepareLearn tokenizes text for learning. It returns nil if text
+// is too short to learn.
+func (b *Cobe2Brain) prepareLearn(text string, rec *LearnRecord) *learnJob {
+	tokens := b.tok.Split(text)
+
+	// skip learning if too few tokens (but don't count spaces)
+	if countGoodTokens(tokens) <= b.graph.order {
+		stats.Inc("learn.skipped", 1, 1.0)
+		return nil
+	}
+
+	if reason := b.checkLearn(text, tokens); reason != "" {
+		rejectLearn(reason)
+		return nil
+	}
+
+	stats.Inc("learn.attempted", 1, 1.0)
+
+	job := &learnJob{
+		rec:     rec,
+		text:    text,
+		tokens:  tokens,
+		classes: make([]TokenClass, len(tokens)),
+		lang:    b.graph.detectLanguage(text),
+		ids:     make([]tokenID, len(tokens)),
+	}
+
+	for i, token := range tokens {
+		if token == " " {
+			job.ids[i] = spaceTokenID
+		} else {
+			job.classes[i] = b.tok.Class(token)
+		}
+	}
+
+	return job
+}
+
+// resolveCached fills in the ids of job's tokens from cache, and its
+// edges if every token was found.
+func (b *Cobe2Brain) resolveCached(job *learnJob, cache *idCache) {
+	complete := true
+	for i, token := range job.tokens {
+		if job.ids[i] != 0 {
+			continue
+		}
+
+		if id, ok := cache.get(token); ok {
+			job.ids[i] = tokenID(id)
+		} else {
+			complete = false
+		}
+	}
+
+	if complete {
+		job.edges = b.chainEdges(job.ids)
+	}
+}
+
+func (b *Cobe2Brain) chainEdges(ids []tokenID) []edge {
+	order := b.graph.order
+	return toEdges(order, b.toChain(order, ids))
+}
+
+// writeLearn creates job's missing tokens and nodes and adds its
+// edges to the graph. The caches, if not nil, are used to look up and
+// remember ids. It returns false if job is a duplicate that wasn't
+// learned.
+func (b *Cobe2Brain) writeLearn(job *learnJob, tokens *idCache, nodes *idCache) bool {
+	if b.graph.seenLine(job.text) {
+		rejectLearn("duplicate")
+		return false
+	}
+
+	for i, token := range job.tokens {
+		if job.ids[i] != 0 {
+			continue
+		}
+
+		id := b.graph.getOrCreateClassToken(token, job.classes[i])
+		job.ids[i] = id
+
+		if tokens != nil {
+			tokens.put(token, int64(id))
+		}
+	}
+
+	if job.lang != "" {
+		b.graph.addLangStems(job.tokens, job.ids, job.lang)
+	}
+
+	if job.edges == nil {
+		job.edges = b.chainEdges(job.ids)
+	}
+
+	getNode := func(ids []tokenID) nodeID {
+		if nodes == nil {
+			return b.graph.getOrCreateNode(ids)
+		}
+
+		key := nodeKey(ids)
+		if id, ok := nodes.get(key); ok {
+			return nodeID(id)
+		}
+
+		node := b.graph.getOrCreateNode(ids)
+		nodes.put(key, int64(node))
+		return node
+	}
+
+	ts := time.Now()
+	if job.rec != nil && !job.rec.Timestamp.IsZero() {
+		ts = job.rec.Timestamp
+	}
+
+	var prevNode nodeID
+	var path []nodeID
+	for _, e := range job.edges {
+		if prevNode == 0 {
+			prevNode = getNode(e.prev)
+			path = append(path, prevNode)
+		}
+		nextNode := getNode(e.next)
+
+		b.graph.addEdge(prevNode, nextNode, e.hasSpace, ts)
+		prevNode = nextNode
+		path = append(path, nextNode)
+	}
+
+	b.graph.addSpans(path)
+	b.graph.addLine(job.rec, job.text, path, job.edges)
+
+	stats.Inc("learn.succeeded", 1, 1.0)
+	return true
+}
+
+func nodeKey(ids []tokenID) string {
+	var buf []byte
+	for _, id := range ids {
+		buf = strconv.AppendInt(buf, int64(id), 10)
+		buf = append(buf, ',')
+	}
+
+	return string(buf)
+}
+
+// idCache maps text to database ids. It's emptied when it fills up,
+// to bound its memory on huge imports.
+type idCache struct {
+	lock sync.RWMutex
+	ids  map[string]int64
+	max  int
+}
+
+func newIDCache(max int) *idCache {
+	return &idCache{ids: make(map[string]int64), max: max}
+}
+
+func (c *idCache) get(key string) (int64, bool) {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	id, ok := c.ids[key]
+	return id, ok
+}
+
+func (c *idCache) put(key string, id int64) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if len(c.ids) >= c.max {
+		c.ids = make(map[string]int64)
+	}
+
+	c.ids[key] = id
+}
+
+// reset empties the cache, when the ids in it may no longer exist.
+func (c *idCache) reset() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.ids = make(map[string]int64)
+}
+
+const (
+	// learnStreamBatch is the number of lines LearnStream writes
+	// in each transaction.
+	learnStreamBatch = 1000
+
+	// learnStreamCache is the maximum size of its id caches.
+	learnStreamCache = 1 << 20
+)
+
+// LearnStream learns each record received from recs, as LearnRecord
+// does, until recs is closed or ctx is done. Records are tokenized by
+// a worker per CPU, and a single writer adds them to the brain in
+// batched transactions, so bulk learning isn't limited to one core.
+// Records may be learned out of order.
+//
+// LearnStream manages its own batches, so it can't be called between
+// BeginBatch and CommitBatch. It returns ctx.Err() if ctx is done
+// first; everything learned until then is committed.
+func (b *Cobe2Brain) LearnStream(ctx context.Context, recs <-chan LearnRecord) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	workers := runtime.NumCPU()
+	jobs := make(chan *learnJob, workers*16)
+	tokens := newIDCache(learnStreamCache)
+
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			b.prepareStream(ctx, recs, jobs, tokens)
+		}()
+	}
+
+	go func() {
+		wg.Wait()
+		close(jobs)
+	}()
+
+	err := b.writeStream(jobs, tokens)
+	if err != nil {
+		return err
+	}
+
+	return ctx.Err()
+}
+
+func (b *Cobe2Brain) prepareStream(ctx context.Context, recs <-chan LearnRecord, jobs chan<- *learnJob, tokens *idCache) {
+	for {
+		var rec LearnRecord
+		var ok bool
+
+		select {
+		case <-ctx.Done():
+			return
+		case rec, ok = <-recs:
+			if !ok {
+				return
+			}
+		}
+
+		text, ok := b.scrubLearn(rec.Text)
+		if !ok {
+			continue
+		}
+
+		lines := []string{text}
+		if b.splitSentences {
+			lines = SplitSentences(text)
+		}
+
+		for _, line := range lines {
+			// Each line keeps the record's author, channel
+			// and time, with its own text.
+			lineRec := rec
+			lineRec.Text = line
+
+			job := b.prepareLearn(line, &lineRec)
+			if job == nil {
+				continue
+			}
+
+			b.resolveCached(job, tokens)
+
+			select {
+			case <-ctx.Done():
+				return
+			case jobs <- job:
+			}
+		}
+	}
+}
+
+// writeStream writes jobs to the graph until the channel is closed.
+// If a batch fails to commit, the ids it created are gone, so the
+// caches are emptied.
+func (b *Cobe2Brain) writeStream(jobs <-chan *learnJob, tokens *idCache) error {
+	nodes := newIDCache(learnStreamCache)
+
+	commit := func() error {
+		err := b.graph.commit()
+		if err != nil {
+			tokens.reset()
+			nodes.reset()
+		}
+
+		return err
+	}
+
+	err := b.graph.begin()
+	if err != nil {
+		return err
+	}
+
+	var n int
+	for job := range jobs {
+		b.writeLearn(job, tokens, nodes)
+
+		n++
+		if n%learnStreamBatch != 0 {
+			continue
+		}
+
+		err = commit()
+		if err == nil {
+			err = b.graph.begin()
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return commit()
+}
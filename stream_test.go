@@ -0,0 +1,180 @@
+package cobe
+
+import (
+	"bufio"
+	"context"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func edgeTotals(t *testing.T, b *Cobe2Brain) (int64, int64, int64) {
+	var tokens, nodes, edges int64
+
+	err := b.graph.db.QueryRow("SELECT (SELECT count(*) FROM tokens), "+
+		"(SELECT count(*) FROM nodes), (SELECT sum(count) FROM edges)").
+		Scan(&tokens, &nodes, &edges)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return tokens, nodes, edges
+}
+
+func TestLearnStream(t *testing.T) {
+	var lines []string
+
+	f, err := os.Open("data/pg11.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := bufio.NewScanner(f)
+	for s.Scan() && len(lines) < 500 {
+		lines = append(lines, s.Text())
+	}
+	f.Close()
+
+	open := func() (*Cobe2Brain, string) {
+		filename, err := tmpCopy("data/pg11.brain")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		b, err := OpenCobe2Brain(filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return b, filename
+	}
+
+	serial, filename := open()
+	defer os.Remove(filename)
+	defer serial.Close()
+
+	for _, line := range lines {
+		serial.Learn(line)
+	}
+
+	stream, filename := open()
+	defer os.Remove(filename)
+	defer stream.Close()
+
+	recs := make(chan LearnRecord)
+	go func() {
+		for _, line := range lines {
+			recs <- LearnRecord{Text: line}
+		}
+		close(recs)
+	}()
+
+	err = stream.LearnStream(context.Background(), recs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t1, n1, e1 := edgeTotals(t, serial)
+	t2, n2, e2 := edgeTotals(t, stream)
+
+	if t1 != t2 || n1 != n2 || e1 != e2 {
+		t.Errorf("Stream learned %d tokens, %d nodes, %d edges; expected %d, %d, %d",
+			t2, n2, e2, t1, n1, e1)
+	}
+}
+
+func TestLearnStreamCancel(t *testing.T) {
+	filename, err := tmpCopy("data/pg11.brain")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(filename)
+
+	b, err := OpenCobe2Brain(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	recs := make(chan LearnRecord)
+	go func() {
+		recs <- LearnRecord{Text: "the zyzzyva crawled over the kumquat"}
+		cancel()
+	}()
+
+	err = b.LearnStream(ctx, recs)
+	if err != context.Canceled {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+
+	// The brain is usable for batches again.
+	if err = b.BeginBatch(); err != nil {
+		t.Fatal(err)
+	}
+	b.CommitBatch()
+}
+
+// Streamed records keep their author, channel and time, and each
+// sentence is recorded with its own text.
+func TestLearnStreamRecords(t *testing.T) {
+	b, filename := newTestBrain(t)
+	defer os.Remove(filename)
+	defer b.Close()
+
+	err := b.SetProvenance()
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.SetSplitSentences(true)
+
+	when := time.Date(2014, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	recs := make(chan LearnRecord, 1)
+	recs <- LearnRecord{Text: "The cat sat on the mat. The dog lay on the rug.",
+		Author: "alice", Channel: "#cobe", Timestamp: when}
+	close(recs)
+
+	err = b.LearnStream(context.Background(), recs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rows, err := b.graph.db.Query("SELECT author, channel, time, text " +
+		"FROM learned_lines ORDER BY text")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+
+	var got []string
+	for rows.Next() {
+		var author, channel, text string
+		var ts int64
+		rows.Scan(&author, &channel, &ts, &text)
+
+		if author != "alice" || channel != "#cobe" || ts != when.Unix() {
+			t.Errorf("Expected alice in #cobe at %d, got %s in %s at %d",
+				when.Unix(), author, channel, ts)
+		}
+
+		got = append(got, text)
+	}
+
+	expected := []string{"The cat sat on the mat.", "The dog lay on the rug."}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected lines %q, got %q", expected, got)
+	}
+}
+
+func TestIDCacheReset(t *testing.T) {
+	c := newIDCache(10)
+	c.put("cat", 1)
+	c.reset()
+
+	if _, ok := c.get("cat"); ok {
+		t.Error("Expected an empty cache after reset")
+	}
+}
@@ -0,0 +1,44 @@
+package cobe
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// ParseSynonyms reads a synonym list from r and calls f with each
+// equivalence class it finds.
+//
+// Each line holds one class: whitespace-separated words, the first of
+// which names the class. Blank lines and lines starting with # are
+// ignored:
+//
+//	# laughter
+//	lol haha lmao rofl
+//	colour color
+func ParseSynonyms(r io.Reader, f func(class string, words ...string) error) error {
+	s := bufio.NewScanner(r)
+
+	var line int
+	for s.Scan() {
+		line++
+
+		text := strings.TrimSpace(s.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		words := strings.Fields(text)
+		if len(words) < 2 {
+			return fmt.Errorf("line %d: synonym class needs two or more words", line)
+		}
+
+		err := f(words[0], words...)
+		if err != nil {
+			return fmt.Errorf("line %d: %s", line, err)
+		}
+	}
+
+	return s.Err()
+}
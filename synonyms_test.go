@@ -0,0 +1,115 @@
+package cobe
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestParseSynonyms(t *testing.T) {
+	text := `
+# laughter
+lol haha lmao
+
+colour color
+`
+
+	var classes []string
+	var words [][]string
+
+	err := ParseSynonyms(strings.NewReader(text), func(class string, w ...string) error {
+		classes = append(classes, class)
+		words = append(words, w)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !eq(classes, []string{"lol", "colour"}) {
+		t.Errorf("Unexpected classes: %v", classes)
+	}
+
+	if len(words) != 2 || !eq(words[0], []string{"lol", "haha", "lmao"}) {
+		t.Errorf("Unexpected words: %v", words)
+	}
+
+	err = ParseSynonyms(strings.NewReader("lonely\n"), func(string, ...string) error {
+		return nil
+	})
+	if err == nil {
+		t.Error("Expected an error for a single word class")
+	}
+}
+
+func TestSynonyms(t *testing.T) {
+	filename, err := tmpCopy("data/pg11.brain")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer os.Remove(filename)
+
+	g, err := openGraph(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer g.close()
+
+	rabbit, err := g.getTokenID("rabbit")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if ids := g.getTokensBySynonym("Bunny"); len(ids) != 0 {
+		t.Errorf("Expected no synonym tokens before addSynonyms, got %v", ids)
+	}
+
+	err = g.addSynonyms("rabbit", []string{"rabbit", "bunny"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ids := g.getTokensBySynonym("Bunny")
+	if !containsId(ids, rabbit) {
+		t.Errorf("Expected Bunny to match %d, was %v", rabbit, ids)
+	}
+
+	// Synonyms match tokens whatever their case, even without
+	// case folding enabled.
+	alice, err := g.getTokenID("Alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = g.addSynonyms("girl", []string{"girl", "alice"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ids = g.getTokensBySynonym("girl")
+	if !containsId(ids, alice) {
+		t.Errorf("Expected girl to match Alice (%d), was %v", alice, ids)
+	}
+
+	var id, parent, notused int
+	var plan string
+	err = g.db.QueryRow("EXPLAIN QUERY PLAN SELECT id FROM tokens "+
+		"WHERE text = ? COLLATE NOCASE", "alice").Scan(&id, &parent, &notused, &plan)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(plan, "tokens_text_nocase") {
+		t.Errorf("Expected case-insensitive token lookups to use an index: %s", plan)
+	}
+
+	err = g.delSynonyms()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if ids := g.getTokensBySynonym("Bunny"); len(ids) != 0 {
+		t.Errorf("Expected no synonym tokens after delSynonyms, got %v", ids)
+	}
+}
@@ -0,0 +1,126 @@
+package cobe
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// TokenClass describes the kind of text in a token. Classes are
+// stored in the brain, so new ones must only be added at the end.
+type TokenClass int
+
+const (
+	// UnknownToken is the class of the end token, and of tokens
+	// learned by something that doesn't record classes.
+	UnknownToken TokenClass = iota
+
+	// WordToken contains letters, possibly mixed with digits.
+	WordToken
+
+	// NumberToken is a run of digits with optional sign and
+	// decimal, grouping or time separators.
+	NumberToken
+
+	// PunctuationToken is made entirely of punctuation marks.
+	PunctuationToken
+
+	// SymbolToken is any other run of non-word characters,
+	// including punctuation joined by whitespace.
+	SymbolToken
+
+	// URLToken is a scheme followed by non-space characters.
+	URLToken
+
+	// EmojiToken contains an emoji or a known emoticon.
+	EmojiToken
+
+	// MentionToken is an @nick, for tokenizers that keep them
+	// together.
+	MentionToken
+
+	// PlaceholderToken stands in for personal data removed by the
+	// scrubber, like scrubbed:email.
+	PlaceholderToken
+)
+
+var tokenClassNames = []string{
+	"unknown",
+	"word",
+	"number",
+	"punctuation",
+	"symbol",
+	"url",
+	"emoji",
+	"mention",
+	"placeholder",
+}
+
+func (c TokenClass) String() string {
+	if c >= 0 && int(c) < len(tokenClassNames) {
+		return tokenClassNames[c]
+	}
+
+	return "unknown"
+}
+
+var (
+	isWordRegexp    = regexp.MustCompile(`\w`)
+	isURLRegexp     = regexp.MustCompile(`^\w+://\S+$|^(?i:www\.|mailto:)\S+$|^\w+:\S*/\S*$`)
+	isNumberRegexp  = regexp.MustCompile(`^[+-]?\d+([.,:]\d+)*$`)
+	isMentionRegexp = regexp.MustCompile(`^@[\w'-]+$`)
+)
+
+// classifyToken returns the class of a single token, as produced by
+// one of the tokenizers.
+func classifyToken(token string) TokenClass {
+	switch {
+	case token == "":
+		return UnknownToken
+	case isPlaceholder(token):
+		return PlaceholderToken
+	case isURLRegexp.MatchString(token):
+		return URLToken
+	case isMentionRegexp.MatchString(token):
+		return MentionToken
+	case isNumberRegexp.MatchString(token):
+		return NumberToken
+	case isWordRegexp.MatchString(token):
+		if _, ok := emoticonStem(token); ok {
+			// xD and friends.
+			return EmojiToken
+		}
+
+		return WordToken
+	case isEmoji(token):
+		return EmojiToken
+	case strings.IndexFunc(token, isNotPunct) == -1:
+		return PunctuationToken
+	}
+
+	return SymbolToken
+}
+
+func isEmoji(token string) bool {
+	if _, ok := emoticonStem(token); ok {
+		return true
+	}
+
+	return strings.IndexFunc(token, isEmojiRune) != -1
+}
+
+// isEmojiRune is a rough test for the pictographic ranges of Unicode.
+func isEmojiRune(r rune) bool {
+	switch {
+	case r >= 0x1F000 && r <= 0x1FAFF:
+		return true
+	case r >= 0x2600 && r <= 0x27BF:
+		return true
+	}
+
+	return false
+}
+
+func isNotPunct(r rune) bool {
+	return !unicode.IsPunct(r)
+}
@@ -0,0 +1,41 @@
+package cobe
+
+import "testing"
+
+func TestClassifyToken(t *testing.T) {
+	var tests = []struct {
+		token    string
+		expected TokenClass
+	}{
+		{"", UnknownToken},
+		{"Alice", WordToken},
+		{"don't", WordToken},
+		{"x86", WordToken},
+		{"42", NumberToken},
+		{"-1", NumberToken},
+		{"3.14", NumberToken},
+		{"1,000", NumberToken},
+		{".", PunctuationToken},
+		{"?!", PunctuationToken},
+		{". . .", SymbolToken},
+		{"+", SymbolToken},
+		{"http://example.com/foo", URLToken},
+		{"www.example.com", URLToken},
+		{"mailto:alice@example.com", URLToken},
+		{"10:30", NumberToken},
+		{":)", EmojiToken},
+		{"xD", EmojiToken},
+		{"😂", EmojiToken},
+		{"@alice", MentionToken},
+		{"scrubbed:phone", PlaceholderToken},
+		{"scrubbed:other", WordToken},
+	}
+
+	for ti, tt := range tests {
+		class := classifyToken(tt.token)
+		if class != tt.expected {
+			t.Errorf("[%d] %q: expected %s, was %s", ti, tt.token,
+				tt.expected, class)
+		}
+	}
+}
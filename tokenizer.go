@@ -9,10 +9,15 @@ import (
 type tokenizer interface {
 	Split(string) []string
 	Join([]string) string
+	Class(string) TokenClass
 }
 
 type whitespaceTokenizer struct{}
 
+func (t *whitespaceTokenizer) Class(token string) TokenClass {
+	return classifyToken(token)
+}
+
 func (t *whitespaceTokenizer) Split(str string) []string {
 	return strings.Fields(str)
 }
@@ -29,6 +34,7 @@ func (t *whitespaceTokenizer) Join(strs []string) string {
 //    internal whitespace
 //  * the whitespace between word or non-word tokens
 //  * an HTTP url, [word]: followed by any run of non-space characters.
+//  * a scrubber placeholder, like scrubbed:email
 //
 // This tokenizer collapses multiple spaces in a whitespace token into
 // a single space character.
@@ -41,7 +47,8 @@ type cobeTokenizer struct {
 
 func newCobeTokenizer() *cobeTokenizer {
 	re := regexp.MustCompile(`(` +
-		`\w+:\S+` +
+		`scrubbed:[a-z]+\b` +
+		`|\w+:\S+` +
 		`|[\w'-]+` +
 		`|[^\w\s][^\w]*[^\w\s]` +
 		`|[^\w\s]` +
@@ -73,6 +80,10 @@ func (t *cobeTokenizer) Join(strs []string) string {
 	return strings.Join(strs, "")
 }
 
+func (t *cobeTokenizer) Class(token string) TokenClass {
+	return classifyToken(token)
+}
+
 // A MegaHAL compatible tokenizer. Any of these are tokens:
 //
 //   * one or more consecutive alpha characters (plus apostrophe)
@@ -104,6 +115,12 @@ func (t *megaHALTokenizer) Split(str string) []string {
 	return t.re.FindAllString(strings.ToUpper(str), -1)
 }
 
+// MegaHAL tokens are uppercased, but otherwise classify the same
+// as cobe tokens.
+func (t *megaHALTokenizer) Class(token string) TokenClass {
+	return classifyToken(token)
+}
+
 // Capitalize the first alpha character in the reply, along with the
 // first alpha character that follows any of [.?!] and a space.
 func (t *megaHALTokenizer) Join(strs []string) string {
@@ -48,6 +48,7 @@ func TestCobeTokenizer(t *testing.T) {
 		{"cobe://www.google.com/", []string{"cobe://www.google.com/"}},
 		{"cobe:www.google.com/", []string{"cobe:www.google.com/"}},
 		{":foo", []string{":", "foo"}},
+		{"mail scrubbed:email, ok", []string{"mail", " ", "scrubbed:email", ",", " ", "ok"}},
 
 		{"testing :    (", []string{"testing", " ", ":    ("}},
 		{"testing          :    (", []string{"testing", " ", ":    ("}},
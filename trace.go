@@ -0,0 +1,116 @@
+package cobe
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// An EdgeTrace shows one edge of a reply's node path and what it
+// added to the reply's score.
+type EdgeTrace struct {
+	// Tokens are the tokens of the node the edge leads to. End
+	// tokens are empty.
+	Tokens []string
+
+	// Count is the edge's count, and NodeCount the count of the
+	// node it leaves.
+	Count     int64
+	NodeCount int64
+
+	// Weight and NodeWeight are the decayed weights of the edge
+	// and node, as of the trace, when the brain has decay enabled.
+	Weight     float64
+	NodeWeight float64
+
+	// Logprob is log2(Count / NodeCount), or log2(Weight /
+	// NodeWeight) with decay enabled.
+	Logprob float64
+}
+
+// A ReplyTrace breaks down the score of a reply.
+type ReplyTrace struct {
+	// Edges[i] leads from node i of the reply's path to node i+1.
+	Edges []EdgeTrace
+
+	// Decayed is true if the edges were scored by their decayed
+	// weights rather than their counts.
+	Decayed bool
+
+	// Pivot is the index of the node the reply was searched from,
+	// so Edges[Pivot-1] leads to it.
+	Pivot int
+
+	// Info is the information content of the reply, the negated
+	// sum of the edges' Logprob.
+	Info float64
+
+	// Words is the reply length the scorer counts, and Penalty
+	// what it divides Info by for that length.
+	Words   int
+	Penalty float64
+
+	// Score is Info / Penalty.
+	Score float64
+}
+
+// Trace shows how the reply was scored, edge by edge.
+func (r *Reply) Trace() *ReplyTrace {
+	g := r.graph
+
+	t := &ReplyTrace{Pivot: r.pivot, Decayed: g.halfLife > 0}
+	for i := 0; i < len(r.nodes)-1; i++ {
+		count, nodeCount := g.getEdgeCounts(r.nodes[i], r.nodes[i+1])
+		logprob := g.getEdgeLogprob(r.nodes[i], r.nodes[i+1])
+
+		e := EdgeTrace{
+			Tokens:    g.getNodeTokens(r.nodes[i+1]),
+			Count:     count,
+			NodeCount: nodeCount,
+			Logprob:   logprob,
+		}
+
+		if t.Decayed {
+			e.Weight, e.NodeWeight = g.getDecayedWeights(r.nodes[i], r.nodes[i+1])
+		}
+
+		t.Edges = append(t.Edges, e)
+		t.Info -= logprob
+	}
+
+	t.Words = replyWords(r)
+	t.Penalty = lengthPenalty(t.Words)
+	t.Score = t.Info / t.Penalty
+
+	return t
+}
+
+func (t *ReplyTrace) String() string {
+	var buf bytes.Buffer
+
+	if t.Decayed {
+		fmt.Fprintf(&buf, "   %4s %6s %6s %9s %9s %8s  %s\n", "edge", "count", "node",
+			"weight", "node", "logprob", "tokens")
+	} else {
+		fmt.Fprintf(&buf, "   %4s %6s %6s %8s  %s\n", "edge", "count", "node", "logprob", "tokens")
+	}
+
+	for i, e := range t.Edges {
+		mark := " "
+		if i == t.Pivot-1 {
+			mark = "*"
+		}
+
+		if t.Decayed {
+			fmt.Fprintf(&buf, " %s %4d %6d %6d %9.3g %9.3g %8.3f  %q\n", mark, i,
+				e.Count, e.NodeCount, e.Weight, e.NodeWeight, e.Logprob, e.Tokens)
+		} else {
+			fmt.Fprintf(&buf, " %s %4d %6d %6d %8.3f  %q\n", mark, i, e.Count,
+				e.NodeCount, e.Logprob, e.Tokens)
+		}
+	}
+
+	fmt.Fprintf(&buf, "info %.3f, %d words, penalty %.3f, score %.3f",
+		t.Info, t.Words, t.Penalty, t.Score)
+
+	return buf.String()
+}
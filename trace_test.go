@@ -0,0 +1,76 @@
+package cobe
+
+import (
+	"math"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTrace(t *testing.T) {
+	filename, err := tmpCopy("data/pg11.brain")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(filename)
+
+	b, err := OpenCobe2Brain(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reply := b.BestReply("Alice", DefaultReplyOptions)
+	if reply == nil {
+		t.Fatal("got a nil reply")
+	}
+
+	trace := reply.Trace()
+
+	if len(trace.Edges) != len(reply.nodes)-1 {
+		t.Errorf("Expected %d edges, got %d", len(reply.nodes)-1, len(trace.Edges))
+	}
+
+	if score := b.scorer.Score(reply); math.Abs(score-trace.Score) > 1e-9 {
+		t.Errorf("Expected score %f, trace has %f", score, trace.Score)
+	}
+
+	if math.Abs(trace.Info/trace.Penalty-trace.Score) > 1e-9 {
+		t.Errorf("Inconsistent trace: %s", trace)
+	}
+
+	pivot := trace.Edges[trace.Pivot-1].Tokens[0]
+	if !strings.Contains(strings.ToLower(pivot), "alice") {
+		t.Errorf("Expected an Alice pivot, got %q\n%s", pivot, trace)
+	}
+}
+
+func TestTraceCounts(t *testing.T) {
+	b, filename := newTestBrain(t)
+	defer os.Remove(filename)
+
+	b.Learn("the cat sat on the mat")
+	b.Learn("the cat sat on the mat")
+
+	reply := b.BestReply("cat", ReplyOptions{Duration: 50 * time.Millisecond})
+	if reply == nil {
+		t.Fatal("got a nil reply")
+	}
+
+	trace := reply.Trace()
+
+	// Every edge was learned twice, into nodes seen twice.
+	for i, e := range trace.Edges {
+		if e.Count != 2 || e.NodeCount != 2 || e.Logprob != 0 {
+			t.Errorf("[%d] unexpected edge: %+v", i, e)
+		}
+	}
+
+	if tokens := trace.Edges[0].Tokens; !eq(tokens, []string{"", "", "the"}) {
+		t.Errorf("Unexpected first tokens: %q", tokens)
+	}
+
+	if trace.Info != 0 || trace.Words != 6 || trace.Penalty != 1 {
+		t.Errorf("Unexpected totals:\n%s", trace)
+	}
+}